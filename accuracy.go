@@ -0,0 +1,73 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"math"
+)
+
+// OnAccuracy registers fn to be called once, when the estimate of Pi first
+// stays within tol of π, e.g. to record how many points it takes to reach a
+// given accuracy. fn is called with the number of points and the estimate
+// at which the estimate entered the tolerance.
+//
+// By default, fn is called as soon as the estimate is within tol. With
+// SetAccuracyHold, the estimate must stay within tol for a number of points,
+// so lucky early crossings are ignored.
+// Unlike a stopping condition, the simulation goes on after fn is called.
+// fn is called from the goroutine accumulating the points: it must not
+// block, nor call the functions of this package.
+// A nil fn unregisters the previous one.
+func OnAccuracy(tol float64, fn func(n int, pi float64)) {
+	srv.update(func(cfg *config) {
+		cfg.accuracy = nil
+		if fn != nil {
+			cfg.accuracy = &threshold{tol: tol, fn: fn}
+		}
+	})
+}
+
+// SetAccuracyHold sets the number of points n for which the estimate of Pi
+// must stay within the tolerance of OnAccuracy before its callback is
+// called. The estimate leaving the tolerance restarts the count.
+// The default is 1: the callback is called on the first crossing.
+func SetAccuracyHold(n int) {
+	srv.update(func(cfg *config) {
+		cfg.hold = max(n, 1)
+	})
+}
+
+// threshold tracks the crossing of the tolerance of OnAccuracy.
+// threshold is only accessed from the run loop.
+type threshold struct {
+	tol float64
+	fn  func(n int, pi float64)
+
+	within bool  // whether the estimate is within tol
+	start  point // estimate at which the estimate entered tol
+	fired  bool  // whether fn has been called
+}
+
+// watch checks whether the estimate has stayed within the tolerance of
+// OnAccuracy for long enough, and calls its callback once it has.
+func (srv *server) watch(cfg config) {
+	acc := cfg.accuracy
+	if acc == nil || acc.fired {
+		return
+	}
+	pi := srv.estimate()
+	if !(math.Abs(pi-math.Pi) <= acc.tol) {
+		acc.within = false
+		return
+	}
+	if !acc.within {
+		acc.within = true
+		acc.start = point{N: srv.n, Pi: pi}
+	}
+	if srv.n-acc.start.N+1 >= cfg.hold {
+		acc.fired = true
+		acc.fn(acc.start.N, acc.start.Pi)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"math"
+	"time"
+
+	"gonum.org/v1/plot/vg"
+)
+
+// minAdaptiveDPI is the lowest resolution chosen by WithAdaptiveDPI.
+const minAdaptiveDPI = 18
+
+// WithAdaptiveDPI lowers the resolution of the rendered frames, so the
+// rendering stays under pixelsPerSecond pixels per second at the current
+// frame rate, e.g. to cap the render cost of a fast simulation streamed to
+// many clients. The frame size is kept: the frames trade crispness for
+// throughput.
+//
+// The resolution is scaled down as the frame rate rises, and back up to the
+// resolution of SetPixelSize as it falls, down to 18 dpi.
+// The resolution of the last rendered frame is served by the "/config"
+// endpoint, see Config.
+// A zero or negative budget, the default, keeps the resolution of
+// SetPixelSize.
+func WithAdaptiveDPI(pixelsPerSecond int) Option {
+	return func(cfg *config) {
+		cfg.pixelRate = pixelsPerSecond
+	}
+}
+
+// resolution returns the resolution of the frames of width w and height h,
+// lowered from the configured one to stay within the pixel rate budget at
+// the current frame rate.
+func (srv *server) resolution(cfg config, w, h vg.Length) int {
+	dpi := cfg.dpi
+	if cfg.pixelRate <= 0 || srv.frame.interval <= 0 {
+		return dpi
+	}
+	var (
+		fps  = float64(time.Second) / float64(srv.frame.interval)
+		rate = w.Dots(float64(dpi)) * h.Dots(float64(dpi)) * fps
+	)
+	if rate <= float64(cfg.pixelRate) {
+		return dpi
+	}
+	// the number of pixels scales with the square of the resolution.
+	scaled := float64(dpi) * math.Sqrt(float64(cfg.pixelRate)/rate)
+	return max(min(minAdaptiveDPI, dpi), int(scaled))
+}
+
+// pace updates the moving average of the interval between the frames,
+// with a frame rendering starting at t.
+func (srv *server) pace(t time.Time) {
+	if !srv.frame.beg.IsZero() {
+		dt := t.Sub(srv.frame.beg)
+		switch srv.frame.interval {
+		case 0:
+			srv.frame.interval = dt
+		default:
+			srv.frame.interval += (dt - srv.frame.interval) / 4
+		}
+	}
+	srv.frame.beg = t
+}
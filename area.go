@@ -0,0 +1,48 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// SetReferenceArea sets the known area a of the inside region, e.g. computed
+// analytically, to validate the estimate of Area: the title of the plot then
+// shows the estimate of the area next to a, and its relative error, e.g.
+// "area = 0.78 (true 0.785, err 0.6%)".
+//
+// The inside region is the quarter disk of the [0,1]x[0,1] square, of area
+// π/4, or the disk of UseFullCircle, of area π.
+// A zero, negative or NaN area, the default, shows no reference.
+func SetReferenceArea(a float64) {
+	srv.update(func(cfg *config) {
+		cfg.area = a
+	})
+}
+
+// area returns the estimate of the area of the inside region.
+func (srv *server) area(cfg config) float64 {
+	d := cfg.domain
+	return srv.win / srv.wsum * (d[1] - d[0]) * (d[1] - d[0])
+}
+
+// reference returns the line of the title comparing the estimate of the
+// area to the reference area, or "" if no reference area is set.
+func (srv *server) reference(cfg config) string {
+	if !(cfg.area > 0) {
+		return ""
+	}
+	ref := strconv.FormatFloat(cfg.area, 'g', 4, 64)
+	if srv.n == 0 {
+		return fmt.Sprintf("\narea = n/a (true %s)", ref)
+	}
+	est := srv.area(cfg)
+	return fmt.Sprintf(
+		"\narea = %s (true %s, err %.1f%%)",
+		cfg.format(est), ref, 100*math.Abs(est-cfg.area)/cfg.area,
+	)
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot/plotter"
+)
+
+// Baseline sets the points of a baseline run, e.g. a reference simulation,
+// drawn in a muted color beneath the points of the current run, so the two
+// runs can be compared visually.
+//
+// The baseline is static: its points are not accounted for, neither in n
+// nor in the estimate of Pi, and are not sent to the web clients with
+// WithClientRendering. The baseline is drawn from the next frame on.
+// A nil or empty pts removes the baseline.
+// Baseline is a no-op once the server has stopped.
+func Baseline(pts plotter.XYs) {
+	pts = append(plotter.XYs(nil), pts...)
+	srv.do(func() {
+		srv.baseline = pts
+		srv.gen++
+	})
+}
+
+// LoadBaselineCSV reads the points of a baseline run from r, in the format
+// of LoadCSV, and sets them as the baseline. See Baseline.
+// The baseline is left unchanged if r can not be read.
+func LoadBaselineCSV(r io.Reader) error {
+	var pts plotter.XYs
+	err := readCSV(r, func(x, y float64) {
+		pts = append(pts, plotter.XY{X: x, Y: y})
+	})
+	if err != nil {
+		return err
+	}
+	Baseline(pts)
+	return nil
+}
+
+// baselineColor is the color of the points of the baseline.
+var baselineColor = color.NRGBA{96, 128, 96, 96}
+
+// baselineScatter adds to p the scatter of the points of the baseline, if any.
+func (srv *server) baselineScatter(p *hplot.Plot, cfg config, axes [2]float64) error {
+	if len(srv.baseline) == 0 {
+		return nil
+	}
+	s, err := hplot.NewScatter(cfg.clamped(srv.baseline, axes))
+	if err != nil {
+		return fmt.Errorf("mcpi: could not create scatter of baseline points: %w", err)
+	}
+	s.Color = baselineColor
+	s.Radius = cfg.radius
+	p.Add(s)
+	return nil
+}
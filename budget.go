@@ -0,0 +1,45 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/plot/vg"
+)
+
+// defaultMaxPixels is the default pixel budget of the rendered images,
+// about 256MB of RGBA pixels.
+const defaultMaxPixels = 64 << 20
+
+// SetMaxPixels sets the maximum number of pixels of the rendered images,
+// guarding against huge allocations, e.g. from a mistyped SetPixelSize or
+// a "/render" request.
+// A zero or negative value restores the default, 64 megapixels.
+func SetMaxPixels(n int) {
+	srv.update(func(cfg *config) {
+		if n <= 0 {
+			n = defaultMaxPixels
+		}
+		cfg.maxPixels = n
+	})
+}
+
+// fits returns an error if an image of width w and height h at dpi dots
+// per inch exceeds the pixel budget.
+func (cfg config) fits(w, h vg.Length, dpi int) error {
+	var (
+		pw = math.Round(w.Dots(float64(dpi)))
+		ph = math.Round(h.Dots(float64(dpi)))
+	)
+	if pw*ph > float64(cfg.maxPixels) {
+		return fmt.Errorf(
+			"mcpi: image of %.0fx%.0f pixels exceeds the budget of %d pixels: use a smaller size or resolution",
+			pw, ph, cfg.maxPixels,
+		)
+	}
+	return nil
+}
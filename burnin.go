@@ -0,0 +1,67 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"fmt"
+	"image/color"
+
+	"go-hep.org/x/hep/hplot"
+)
+
+// SetBurnIn discards the first n plotted points from the estimate of Pi,
+// e.g. to leave out the transient of a Markov chain sampler, as in MCMC.
+//
+// The discarded points are not accounted for in n, in the estimate and in
+// the convergence series: the counters start after the burn-in. They are
+// not drawn, unless WithBurnInShown is used.
+// The burn-in only applies before the first accounted point, and to the
+// points plotted one by one: the counts of AddCounts are accounted for.
+// A zero or negative n, the default, disables the burn-in.
+func SetBurnIn(n int) {
+	srv.update(func(cfg *config) {
+		cfg.burnIn = n
+	})
+}
+
+// WithBurnInShown draws the points discarded by SetBurnIn, faded in gray
+// beneath the accounted points.
+func WithBurnInShown() Option {
+	return func(cfg *config) {
+		cfg.showBurnIn = true
+	}
+}
+
+// burnColor is the color of the points discarded by the burn-in.
+var burnColor = color.NRGBA{128, 128, 128, 64}
+
+// burn discards the point (x,y) during the burn-in, and returns whether it did.
+func (srv *server) burn(cfg config, x, y float64) bool {
+	if srv.n > 0 || srv.burnt >= cfg.burnIn {
+		return false
+	}
+	srv.burnt++
+	if cfg.showBurnIn {
+		srv.burned.push(x, y)
+		srv.gen++
+	}
+	return true
+}
+
+// burnScatter adds to p the scatter of the points discarded by the burn-in,
+// if drawn.
+func (srv *server) burnScatter(p *hplot.Plot, cfg config, axes [2]float64) error {
+	if !cfg.showBurnIn || srv.burned.Len() == 0 {
+		return nil
+	}
+	s, err := hplot.NewScatter(cfg.clamped(srv.burned, axes))
+	if err != nil {
+		return fmt.Errorf("mcpi: could not create scatter of burn-in points: %w", err)
+	}
+	s.Color = burnColor
+	s.Radius = cfg.radius
+	p.Add(s)
+	return nil
+}
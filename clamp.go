@@ -0,0 +1,43 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"math"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// WithClampedPoints draws the points beyond the axes of the plot on their
+// edges, so every drawn point is visible, e.g. the points slightly out of a
+// custom domain. Only the drawn positions are clamped: the points are
+// accounted for with their coordinates.
+// By default, the points are drawn at their exact coordinates, and the
+// points beyond the axes are not visible.
+func WithClampedPoints(on bool) Option {
+	return func(cfg *config) {
+		cfg.clamp = on
+	}
+}
+
+// clamped returns the points of xy to draw on the axes, clamped to the axes
+// with WithClampedPoints.
+func (cfg config) clamped(xy plotter.XYer, axes [2]float64) plotter.XYer {
+	if !cfg.clamp {
+		return xy
+	}
+	return clamp{xy, axes[0], axes[1]}
+}
+
+// clamp is an XYer clamping the points of XYer to [lo,hi]x[lo,hi].
+type clamp struct {
+	plotter.XYer
+	lo, hi float64
+}
+
+func (c clamp) XY(i int) (x, y float64) {
+	x, y = c.XYer.XY(i)
+	return math.Min(math.Max(x, c.lo), c.hi), math.Min(math.Max(y, c.lo), c.hi)
+}
@@ -0,0 +1,96 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build js && wasm
+
+// Command mcpi-wasm is the WebAssembly client of the mcpi web plot server.
+//
+// It receives the (x,y) points of each frame from the server and draws them
+// on the page's canvas. See mcpi.WithClientRendering for how to build and
+// serve it.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"syscall/js"
+)
+
+type frame struct {
+	N      int          `json:"n"`
+	Pi     float64      `json:"pi"`
+	Domain [2]float64   `json:"domain"`
+	In     [][2]float64 `json:"in"`
+	Out    [][2]float64 `json:"out"`
+	Done   bool         `json:"done"`
+}
+
+func main() {
+	var (
+		win    = js.Global()
+		doc    = win.Get("document")
+		canvas = doc.Call("getElementById", "plot")
+		ctx    = canvas.Call("getContext", "2d")
+		url    = win.Get("URL").New("data", win.Get("location").Get("href"))
+	)
+	// the data endpoint is resolved relative to the page.
+	url.Set("protocol", strings.Replace(url.Get("protocol").String(), "http", "ws", 1))
+
+	sock := win.Get("WebSocket").New(url.Get("href"))
+	sock.Set("onmessage", js.FuncOf(func(this js.Value, args []js.Value) any {
+		var f frame
+		err := json.Unmarshal([]byte(args[0].Get("data").String()), &f)
+		if err != nil {
+			win.Get("console").Call("error", "mcpi: could not decode frame: "+err.Error())
+			return nil
+		}
+		draw(ctx, canvas.Get("width").Float(), canvas.Get("height").Float(), f)
+		return nil
+	}))
+
+	select {}
+}
+
+func draw(ctx js.Value, w, h float64, f frame) {
+	const (
+		margin = 40
+		radius = 1.5
+	)
+	var (
+		sx = w - 2*margin
+		sy = h - 2*margin
+
+		lo = f.Domain[0]
+		dx = f.Domain[1] - f.Domain[0]
+	)
+
+	ctx.Set("fillStyle", "white")
+	ctx.Call("fillRect", 0, 0, w, h)
+	ctx.Set("strokeStyle", "black")
+	ctx.Call("strokeRect", margin, margin, sx, sy)
+
+	dots := func(pts [][2]float64, color string) {
+		ctx.Set("fillStyle", color)
+		for _, pt := range pts {
+			x := margin + (pt[0]-lo)/dx*sx
+			y := margin + (1-(pt[1]-lo)/dx)*sy
+			ctx.Call("fillRect", x-radius, y-radius, 2*radius, 2*radius)
+		}
+	}
+	dots(f.In, "rgb(255,0,0)")
+	dots(f.Out, "rgb(0,0,255)")
+
+	ctx.Set("fillStyle", "black")
+	ctx.Set("font", "14px sans-serif")
+	ctx.Set("textAlign", "center")
+	pi := "n/a"
+	if f.N > 0 {
+		pi = fmt.Sprint(f.Pi)
+	}
+	ctx.Call("fillText", fmt.Sprintf("n = %d    π = %s", f.N, pi), w/2, margin/2)
+	if f.Done {
+		ctx.Call("fillText", "simulation complete", w/2, h-margin/2)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net/http"
+	"time"
+
+	"gonum.org/v1/plot/vg"
+)
+
+// Config is the effective configuration of the web plot server, as set by
+// the options and the setters of the package.
+// It is also served as JSON by the "/config" endpoint.
+type Config struct {
+	Radius    float64 `json:"radius"`    // radius of the drawn points, in points
+	Inside    string  `json:"inside"`    // color of the inside points, as "#rrggbb"
+	Outside   string  `json:"outside"`   // color of the outside points, as "#rrggbb"
+	DrawLimit [2]int  `json:"drawLimit"` // inside and outside draw limits
+	Precision int     `json:"precision"` // number of digits of the estimate, -1 for the shortest
+	Heatmap   bool    `json:"heatmap"`   // whether to draw the density of the points
+
+	Size   [2]vg.Length `json:"size"`   // width and height of the rendered images, in points
+	DPI    int          `json:"dpi"`    // resolution of the rendered images
+	Domain [2]float64   `json:"domain"` // [min,max] range of the sampled square
+
+	PixelRate int `json:"pixelRate"` // budget of rendered pixels per second, see WithAdaptiveDPI
+	RenderDPI int `json:"renderDPI"` // resolution of the last rendered image, 0 before the first one
+
+	LogBase      float64       `json:"logBase"`      // base of the geometric schedule of the frames
+	TickInterval time.Duration `json:"tickInterval"` // interval of the periodic frames, in nanoseconds
+	BatchSize    int           `json:"batchSize"`    // maximum number of points processed per batch
+}
+
+// CurrentConfig returns the effective configuration of the web plot server,
+// e.g. to check which options took effect.
+func CurrentConfig() Config {
+	var c Config
+	srv.read(func() { c = srv.currentConfig() })
+	return c
+}
+
+// currentConfig returns the effective configuration.
+// currentConfig must be called from the run loop, see server.read.
+func (srv *server) currentConfig() Config {
+	cfg := srv.config()
+	return Config{
+		Radius:       float64(cfg.radius),
+		Inside:       hexColor(cfg.colors[0]),
+		Outside:      hexColor(cfg.colors[1]),
+		DrawLimit:    [2]int{cfg.pmaxIn, cfg.pmaxOut},
+		Precision:    cfg.prec,
+		Heatmap:      cfg.heatmap,
+		Size:         cfg.size,
+		DPI:          cfg.dpi,
+		Domain:       cfg.domain,
+		PixelRate:    cfg.pixelRate,
+		RenderDPI:    srv.frame.dpi,
+		LogBase:      cfg.logBase,
+		TickInterval: srv.tick,
+		BatchSize:    cfg.batch,
+	}
+}
+
+// jsonConfig is the JSON representation of an update of the runtime
+// configuration by the "/config" endpoint.
+// Fields omitted from an update are left unchanged.
+type jsonConfig struct {
+	Radius    *float64 `json:"radius,omitempty"`    // radius of the drawn points, in points
+	Inside    *string  `json:"inside,omitempty"`    // color of the inside points, as "#rrggbb"
+	Outside   *string  `json:"outside,omitempty"`   // color of the outside points, as "#rrggbb"
+	DrawLimit *[2]int  `json:"drawLimit,omitempty"` // inside and outside draw limits
+	Precision *int     `json:"precision,omitempty"` // number of digits of the estimate
+	Heatmap   *bool    `json:"heatmap,omitempty"`   // whether to draw the density of the points
+}
+
+// options validates the update and returns the corresponding options.
+func (jc jsonConfig) options() ([]Option, error) {
+	var opts []Option
+	if jc.Radius != nil {
+		if !(*jc.Radius > 0) {
+			return nil, fmt.Errorf("mcpi: invalid radius %v", *jc.Radius)
+		}
+		opts = append(opts, WithPointRadius(vg.Length(*jc.Radius)))
+	}
+	for i, c := range []*string{jc.Inside, jc.Outside} {
+		if c == nil {
+			continue
+		}
+		col, err := parseColor(*c)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, func(cfg *config) {
+			cfg.colors[i] = col
+		})
+	}
+	if jc.DrawLimit != nil {
+		lim := *jc.DrawLimit
+		opts = append(opts, func(cfg *config) {
+			cfg.pmaxIn = lim[0]
+			cfg.pmaxOut = lim[1]
+		})
+	}
+	if jc.Precision != nil {
+		if p := *jc.Precision; p > 17 {
+			return nil, fmt.Errorf("mcpi: invalid precision %d", p)
+		}
+		opts = append(opts, WithPrecision(*jc.Precision))
+	}
+	if jc.Heatmap != nil {
+		opts = append(opts, WithHeatmap(*jc.Heatmap))
+	}
+	return opts, nil
+}
+
+// configHandle serves the effective configuration on GET, and updates the
+// runtime configuration on POST, with an "application/json" body.
+// An update is applied by the run loop, and a new frame is emitted.
+func (srv *server) configHandle(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !jsonBody(w, r) {
+			return
+		}
+		var jc jsonConfig
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&jc)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("mcpi: could not decode configuration: %v", err), http.StatusBadRequest)
+			return
+		}
+		opts, err := jc.options()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ok := srv.do(func() {
+			Configure(opts...)
+			if srv.n > 0 {
+				srv.emit()
+			}
+		})
+		if !ok {
+			http.Error(w, ErrClosed.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c Config
+	srv.read(func() { c = srv.currentConfig() })
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(c)
+	if err != nil {
+		srv.logger().Error("error encoding configuration", "err", err)
+	}
+}
+
+// hexColor returns the "#rrggbb" representation of c.
+func hexColor(c color.Color) string {
+	v := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", v.R, v.G, v.B)
+}
+
+// parseColor parses a "#rrggbb" color.
+func parseColor(s string) (color.Color, error) {
+	var c color.NRGBA
+	_, err := fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	if err != nil || len(s) != 7 {
+		return nil, fmt.Errorf("mcpi: invalid color %q (want #rrggbb)", s)
+	}
+	c.A = 255
+	return c, nil
+}
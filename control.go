@@ -0,0 +1,54 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// jsonControl is the JSON representation of the state of the simulation,
+// served and updated by the "/control" endpoint.
+type jsonControl struct {
+	Paused bool `json:"paused"` // whether plotted points are left waiting
+}
+
+// controlHandle serves the state of the simulation on GET, and updates it on
+// POST, with an "application/json" body.
+// While paused, the run loop stops accepting points, so that Plot and
+// friends block until the simulation is resumed.
+func (srv *server) controlHandle(w http.ResponseWriter, r *http.Request) {
+	var state jsonControl
+	switch r.Method {
+	case http.MethodGet:
+		srv.do(func() { state.Paused = srv.paused })
+	case http.MethodPost:
+		if !jsonBody(w, r) {
+			return
+		}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&state)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("mcpi: could not decode control: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !srv.do(func() { srv.paused = state.Paused }) {
+			http.Error(w, ErrClosed.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(state)
+	if err != nil {
+		srv.logger().Error("error encoding control", "err", err)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// point is an estimate of Pi with n points, in the convergence series.
+type point struct {
+	N  int     `json:"n"`
+	Pi float64 `json:"pi"`
+}
+
+// converge records the current estimate in the convergence series.
+//
+// The series is sampled at frame boundaries: on each milestone frame
+// (every point below 10 points, every 10 points below 100 points, ...),
+// on each periodic frame (see SetTickInterval) and on the final frame,
+// whether or not the frame is actually rendered.
+func (srv *server) converge() {
+	if srv.n == 0 {
+		return
+	}
+	if k := len(srv.series); k > 0 && srv.series[k-1].N == srv.n {
+		return
+	}
+	srv.series = append(srv.series, point{N: srv.n, Pi: srv.estimate()})
+	if n := srv.config().climit; n > 0 && len(srv.series) > n {
+		srv.series = decimate(srv.series, n)
+	}
+}
+
+// SetConvergenceLimit bounds the convergence series to at most n estimates,
+// so long runs with many frames do not accumulate an unbounded history.
+// Once the limit is exceeded, the series is decimated to keep estimates
+// evenly spaced in log(n): the early estimates are kept, and the later ones
+// thinned out. The last estimate is always kept.
+// A zero or negative n, the default, keeps all the estimates.
+func SetConvergenceLimit(n int) {
+	srv.update(func(cfg *config) {
+		cfg.climit = n
+	})
+}
+
+// decimate thins the series out to at most n estimates, keeping the first
+// estimate of each of n bins evenly spaced in log(n), and the last estimate.
+// decimate reuses the storage of series.
+func decimate(series []point, n int) []point {
+	var (
+		last = series[len(series)-1]
+		lo   = math.Log(float64(series[0].N))
+		hi   = math.Log(float64(last.N))
+		out  = series[:0]
+		prev = -1
+	)
+	if n < 2 || hi == lo {
+		return append(out, last)
+	}
+	for _, pt := range series[:len(series)-1] {
+		bin := int(float64(n-1) * (math.Log(float64(pt.N)) - lo) / (hi - lo))
+		if bin != prev {
+			out = append(out, pt)
+			prev = bin
+		}
+	}
+	if len(out) > 1 && prev == n-1 {
+		out = out[:len(out)-1] // the last estimate takes the bin over.
+	}
+	return append(out, last)
+}
+
+// convergence returns a copy of the convergence series.
+func (srv *server) convergence() []point {
+	var series []point
+	srv.read(func() { series = append([]point(nil), srv.series...) })
+	return series
+}
+
+// ConvergedAt returns the number of points of the first frame whose estimate
+// of Pi was within tol of π, and whether there was one.
+// The estimates are those of the convergence series, see ConvergenceCSV.
+func ConvergedAt(tol float64) (n int, ok bool) {
+	srv.read(func() { n, ok = srv.convergedAt(tol) })
+	return n, ok
+}
+
+// WithConvergenceTolerance marks, on the sparkline of WithTrails, the first
+// frame whose estimate of Pi was within tol of π. See ConvergedAt.
+func WithConvergenceTolerance(tol float64) Option {
+	return func(cfg *config) {
+		cfg.tol = tol
+	}
+}
+
+func (srv *server) convergedAt(tol float64) (int, bool) {
+	for _, s := range srv.series {
+		if math.Abs(s.Pi-math.Pi) <= tol {
+			return s.N, true
+		}
+	}
+	return 0, false
+}
+
+// ConvergenceCSV writes to w the convergence series of the estimate of Pi,
+// as "n,pi" CSV records, sampled at frame boundaries.
+//
+// The series is also served as JSON by the "/convergence.json" endpoint.
+func ConvergenceCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"n", "pi"})
+	for _, s := range srv.convergence() {
+		_ = cw.Write([]string{
+			strconv.Itoa(s.N),
+			strconv.FormatFloat(s.Pi, 'g', -1, 64),
+		})
+	}
+	cw.Flush()
+	err := cw.Error()
+	if err != nil {
+		return fmt.Errorf("mcpi: could not write convergence series: %w", err)
+	}
+	return nil
+}
+
+// convergenceHandle serves the convergence series as JSON.
+func (srv *server) convergenceHandle(w http.ResponseWriter, r *http.Request) {
+	series := srv.convergence()
+	if series == nil {
+		series = []point{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	err := json.NewEncoder(w).Encode(series)
+	if err != nil {
+		srv.logger().Error("error encoding convergence series", "err", err)
+	}
+}
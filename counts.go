@@ -0,0 +1,123 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"errors"
+	"math"
+)
+
+// maxPoints is the maximal number of accounted points, the largest number of
+// points whose sums of weights are exact.
+const maxPoints = 1 << 53
+
+// errTooManyPoints is returned when counts would bring the number of
+// accounted points beyond maxPoints.
+var errTooManyPoints = errors.New("mcpi: too many points")
+
+// AddCounts accounts for total points, of which inside fell inside the disk,
+// e.g. as summarized by a remote worker, without plotting them one by one.
+//
+// The estimate of Pi uses these exact counts, while the scatter plot is
+// complemented with a representative sample of uniformly drawn points,
+// within the draw limits (see SetDrawLimits), thinned like the plotted points
+// by SetPlotFraction, and binned like them beyond the memory budget (see
+// SetMemoryBudget).
+// Invalid counts, negative or with more inside points than total points,
+// are ignored, as are the counts bringing the number of accounted points
+// beyond 2^53, from which the sums of the points are no longer exact.
+func AddCounts(inside, total int) {
+	if inside < 0 || total < inside {
+		return
+	}
+	_ = srv.autostart()
+	_ = srv.count(inside, total)
+}
+
+// count accounts for the aggregated counts of points on the run loop.
+// count returns ErrClosed if the run loop has finished, and
+// errTooManyPoints if the counts would exceed maxPoints.
+func (srv *server) count(inside, total int) error {
+	var err error
+	ok := srv.do(func() {
+		if total > maxPoints-srv.n {
+			err = errTooManyPoints
+			return
+		}
+		cfg := srv.config()
+		prev := srv.n
+		srv.addCounts(cfg, inside, total)
+		srv.counts.publish(srv.counted())
+		srv.advance(cfg, prev)
+	})
+	if !ok {
+		return ErrClosed
+	}
+	return err
+}
+
+// addCounts accounts for the aggregated counts of points.
+func (srv *server) addCounts(cfg config, inside, total int) {
+	srv.n += total
+	srv.win += float64(inside)
+	srv.wsum += float64(total)
+
+	srv.smooth(cfg)
+	srv.watch(cfg)
+	// draw the fraction of the points of SetPlotFraction, within the room
+	// left by the draw limits in each region.
+	var (
+		f    = math.Max(0, math.Min(cfg.frac, 1))
+		nin  = int(math.Round(f * float64(inside)))
+		nout = int(math.Round(f * float64(total-inside)))
+	)
+	nin, nout = drawLens(
+		room(cfg.pmaxIn, srv.in.Len()), room(cfg.pmaxOut, srv.out.Len()),
+		nin, nout,
+	)
+	srv.sample(cfg, nin, true)
+	srv.sample(cfg, nout, false)
+}
+
+// room returns the number of points that can still be drawn under the draw
+// limit pmax, given n drawn points, or a negative number if unlimited.
+func room(pmax, n int) int {
+	if pmax < 0 {
+		return -1
+	}
+	return max(pmax-n, 0)
+}
+
+// sample draws n points uniformly over the domain square, either inside or
+// outside of the unit disk. The points are binned once the memory budget is
+// reached, see SetMemoryBudget.
+func (srv *server) sample(cfg config, n int, inside bool) {
+	var (
+		lo = cfg.domain[0]
+		w  = cfg.domain[1] - cfg.domain[0]
+	)
+	for n > 0 {
+		x := lo + w*cfg.rand()
+		y := lo + w*cfg.rand()
+		if cfg.inside(x, y) != inside {
+			continue
+		}
+		n--
+		switch {
+		case srv.bin(cfg, x, y):
+			// drawn on the heatmap only.
+		case inside:
+			srv.in.push(x, y)
+			if srv.inW != nil {
+				srv.inW = append(srv.inW, 1)
+			}
+		default:
+			srv.out.push(x, y)
+			if srv.outW != nil {
+				srv.outW = append(srv.outW, 1)
+			}
+		}
+	}
+}
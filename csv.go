@@ -0,0 +1,74 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// LoadCSV reads (x,y) points from r and plots them.
+//
+// Each record must hold exactly two fields, x and y, which must be finite.
+// Blank lines are ignored. A first record that can not be parsed as numbers
+// (e.g. "x,y") is considered to be a header and is skipped.
+func LoadCSV(r io.Reader) error {
+	return readCSV(r, Plot)
+}
+
+// readCSV reads (x,y) points from r, as described by LoadCSV, and calls f
+// with each of them.
+func readCSV(r io.Reader, f func(x, y float64)) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	for i := 0; ; i++ {
+		rec, err := cr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("mcpi: could not read CSV record: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rec) != 2 {
+			return fmt.Errorf(
+				"mcpi: line %d: invalid number of fields (got=%d, want=2)",
+				line, len(rec),
+			)
+		}
+
+		x, errx := parseCoord(rec[0])
+		y, erry := parseCoord(rec[1])
+		if errx != nil || erry != nil {
+			if i == 0 {
+				continue // header
+			}
+			return fmt.Errorf(
+				"mcpi: line %d: could not parse point %q: %w",
+				line, rec, errors.Join(errx, erry),
+			)
+		}
+		f(x, y)
+	}
+}
+
+// parseCoord parses the coordinate of a point, which must be finite.
+func parseCoord(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("non-finite coordinate %q", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/font/liberation"
+)
+
+// WithDeterministicOutput makes the rendered images byte-stable for the same
+// plotted points, e.g. for golden-image tests:
+//   - the random choices of the drawn points, see SetPlotFraction, and of
+//     the sampled points of AddCounts, use a source seeded with seed,
+//   - the text uses the bundled Liberation Sans font, whatever the default
+//     style of hplot, unless a font is set with WithFont.
+func WithDeterministicOutput(seed int64) Option {
+	return func(cfg *config) {
+		cfg.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// rand returns a pseudo-random number in [0,1), from the seeded source of
+// WithDeterministicOutput if any.
+// rand must be called from the run loop.
+func (cfg config) rand() float64 {
+	if cfg.rng != nil {
+		return cfg.rng.Float64()
+	}
+	return rand.Float64()
+}
+
+// pinnedStyle returns the style of the plots with WithDeterministicOutput.
+var pinnedStyle = sync.OnceValues(func() (hplot.Style, error) {
+	sty, err := hplot.NewStyle(
+		font.Font{Typeface: "Liberation", Variant: "Sans"},
+		font.NewCache(liberation.Collection()),
+	)
+	if err != nil {
+		return sty, fmt.Errorf("mcpi: could not create pinned style: %w", err)
+	}
+	return sty, nil
+})
@@ -0,0 +1,43 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import "sync/atomic"
+
+// DiagStats is a snapshot of the counters of the web plot server, e.g. to
+// diagnose whether points or frames are lost, or where they wait.
+type DiagStats struct {
+	Pending        int // points waiting to be sent to the run loop, e.g. blocked by SetIntakeRate
+	DroppedPoints  int // points dropped in excess of the intake rate, see WithIntakeDrop
+	FramesRendered int // rendered frames
+	FramesSkipped  int // frames not rendered while no client was connected, see WithRenderWhenObserved
+	FramesDropped  int // frames replaced before a client consumed them, e.g. a slow web client
+	FramesFailed   int // frames that could not be rendered, see the logged errors
+	Clients        int // connected clients, see ClientCount
+}
+
+// Diagnostics returns a snapshot of the counters of the web plot server.
+// The counters are updated atomically: Diagnostics does not wait for the
+// run loop, even when it is busy rendering a frame.
+func Diagnostics() DiagStats {
+	return DiagStats{
+		Pending:        int(srv.stats.pending.Load()),
+		DroppedPoints:  int(srv.stats.dropped.Load()),
+		FramesRendered: int(srv.stats.rendered.Load()),
+		FramesSkipped:  int(srv.stats.skipped.Load()),
+		FramesDropped:  int(srv.hub.dropped.Load()),
+		FramesFailed:   int(srv.stats.failed.Load()),
+		Clients:        srv.hub.len(),
+	}
+}
+
+// stats holds the counters of Diagnostics.
+type stats struct {
+	pending  atomic.Int64 // points waiting to be sent to the run loop
+	dropped  atomic.Int64 // points dropped in excess of the intake rate
+	rendered atomic.Int64 // rendered frames
+	skipped  atomic.Int64 // frames not rendered while no client was connected
+	failed   atomic.Int64 // frames that could not be rendered
+}
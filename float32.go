@@ -0,0 +1,118 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"slices"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// UseFloat32Storage stores the drawn inside and outside points as float32
+// pairs, halving their memory, rather than as float64 pairs.
+//
+// The points are classified, and the estimate of Pi computed, in float64:
+// only the drawn positions lose precision, about 1e-7 in [0,1], well below
+// the resolution of the rendered images. The points of the categories of
+// PlotCategory are still stored as float64 pairs.
+// The points already stored are converted.
+// The points are stored as float64 pairs by default, for exactness.
+func UseFloat32Storage(on bool) {
+	srv.do(func() {
+		srv.in.convert(on)
+		srv.out.convert(on)
+		srv.burned.convert(on)
+	})
+}
+
+// drawnPoints returns the drawn inside, outside and categories points.
+func (srv *server) drawnPoints() []plotter.XYer {
+	pts := []plotter.XYer{srv.in, srv.out}
+	for _, cat := range srv.cats {
+		pts = append(pts, cat)
+	}
+	return pts
+}
+
+// xys is the storage of drawn points, as float64 or float32 pairs.
+// xys implements plotter.XYer.
+type xys struct {
+	f64   plotter.XYs
+	f32   [][2]float32
+	use32 bool // whether the points are stored in f32
+}
+
+func (s xys) Len() int {
+	if s.use32 {
+		return len(s.f32)
+	}
+	return len(s.f64)
+}
+
+func (s xys) XY(i int) (x, y float64) {
+	if s.use32 {
+		return float64(s.f32[i][0]), float64(s.f32[i][1])
+	}
+	return s.f64[i].X, s.f64[i].Y
+}
+
+// slice returns the first n points.
+func (s xys) slice(n int) xys {
+	if s.use32 {
+		s.f32 = s.f32[:n]
+	} else {
+		s.f64 = s.f64[:n]
+	}
+	return s
+}
+
+// cap returns the capacity of the storage, in points.
+func (s xys) cap() int {
+	if s.use32 {
+		return cap(s.f32)
+	}
+	return cap(s.f64)
+}
+
+// size returns the size of the points, in bytes.
+func (s xys) size() int64 {
+	if s.use32 {
+		return 8 * int64(len(s.f32))
+	}
+	return 16 * int64(len(s.f64))
+}
+
+func (s *xys) push(x, y float64) {
+	if s.use32 {
+		s.f32 = append(s.f32, [2]float32{float32(x), float32(y)})
+		return
+	}
+	s.f64 = append(s.f64, plotter.XY{X: x, Y: y})
+}
+
+// grow grows the storage, so n more points can be stored without
+// reallocating it.
+func (s *xys) grow(n int) {
+	if s.use32 {
+		s.f32 = slices.Grow(s.f32, n)
+		return
+	}
+	s.f64 = slices.Grow(s.f64, n)
+}
+
+// convert converts the stored points to float32 pairs, or back to float64
+// pairs.
+func (s *xys) convert(use32 bool) {
+	if s.use32 == use32 {
+		return
+	}
+	var v xys
+	v.use32 = use32
+	v.grow(s.Len())
+	for i := 0; i < s.Len(); i++ {
+		v.push(s.XY(i))
+	}
+	*s = v
+}
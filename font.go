@@ -0,0 +1,125 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"fmt"
+
+	"go-hep.org/x/hep/hplot"
+	"golang.org/x/image/font/sfnt"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/text"
+)
+
+// WithFont draws the text of the plot with the provided font face.
+// The π of the title is replaced by "pi" if the face has no glyph for it.
+func WithFont(face font.Face) Option {
+	return func(cfg *config) {
+		cfg.face = &face
+	}
+}
+
+// WithASCII writes "pi" instead of "π" in the title of the plot,
+// for environments lacking the glyph.
+func WithASCII() Option {
+	return func(cfg *config) {
+		cfg.ascii = true
+	}
+}
+
+// WithMathText draws the estimate of the title and the axis labels of the
+// plot as LaTeX math, e.g. "$\pi = 3.1416$" with an italic n, x and y, for
+// academic output. The segments of the name of the plot between "$" are
+// drawn as math too, see SetName.
+//
+// The text falls back to plain text when the font has no serif variant for
+// the math, e.g. with WithFont, with WithASCII, or when a math segment of
+// the name can not be parsed.
+// The text is plain by default.
+func WithMathText(on bool) Option {
+	return func(cfg *config) {
+		cfg.math = on
+	}
+}
+
+// latex draws the title of p, with its math lines title, and the axis
+// labels of p as LaTeX math, unless unsupported.
+func (cfg config) latex(p *hplot.Plot, title string) {
+	if cfg.pi() != "π" {
+		return // no glyph for π.
+	}
+	var (
+		sty   = p.Title.TextStyle
+		cache = p.TextHandler.Cache()
+		serif = font.Font{Typeface: sty.Font.Typeface, Variant: "Serif"}
+	)
+	if !cache.Has(serif) {
+		return
+	}
+	if cfg.name != "" {
+		title = cfg.name + "\n" + title
+	}
+	hdlr := mathText{
+		plain: text.Plain{Fonts: cache},
+		tex:   text.Latex{Fonts: cache},
+	}
+	for _, line := range hdlr.Lines(title) {
+		if !parses(hdlr, line, sty.Font) {
+			return
+		}
+	}
+	p.Title.Text = title
+	p.Title.TextStyle.Handler = hdlr
+	p.X.Label.Text = "$x$"
+	p.X.Label.TextStyle.Handler = hdlr
+	p.Y.Label.Text = "$y$"
+	p.Y.Label.TextStyle.Handler = hdlr
+}
+
+// parses reports whether the text handler hdlr can lay out txt.
+func parses(hdlr text.Handler, txt string, fnt font.Font) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	hdlr.Box(txt, fnt)
+	return true
+}
+
+// style applies the configured font to the plot p, or the pinned one of
+// WithDeterministicOutput.
+func (cfg config) style(p *hplot.Plot) error {
+	if cfg.face == nil {
+		if cfg.rng != nil {
+			sty, err := pinnedStyle()
+			if err != nil {
+				return err
+			}
+			sty.Apply(p)
+		}
+		return nil
+	}
+	sty, err := hplot.NewStyle(cfg.face.Font, font.NewCache(font.Collection{*cfg.face}))
+	if err != nil {
+		return fmt.Errorf("mcpi: could not create style of font %q: %w", cfg.face.Font.Typeface, err)
+	}
+	sty.Apply(p)
+	return nil
+}
+
+// pi returns the name of Pi displayed in the title.
+func (cfg config) pi() string {
+	if cfg.ascii || (cfg.face != nil && !hasGlyph(cfg.face, 'π')) {
+		return "pi"
+	}
+	return "π"
+}
+
+// hasGlyph reports whether the face holds a glyph for r.
+func hasGlyph(face *font.Face, r rune) bool {
+	i, err := face.Face.GlyphIndex(new(sfnt.Buffer), r)
+	return err == nil && i != 0
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// Frame is a frame of the plot, as sent to the web clients.
+type Frame struct {
+	N    int       // number of points
+	Pi   float64   // estimate of Pi, NaN without points
+	Time time.Time // emission time
+	Done bool      // whether this is the final frame, see Quit
+
+	// PNG is the PNG-encoded image of the frame, shared between the
+	// observers: it must not be modified.
+	// PNG is nil when the frames are rendered client-side, see WithClientRendering.
+	PNG []byte
+}
+
+// Frames returns the sequence of the emitted frames, for in-process
+// observers:
+//
+//	for frame := range mcpi.Frames(ctx) {
+//		log.Printf("n=%d, pi=%v", frame.N, frame.Pi)
+//	}
+//
+// The sequence starts with the last emitted frame, if any, and ends when
+// ctx is cancelled or once the final frame has been yielded.
+// Like a web client, an observer slower than the frame rate only receives
+// the latest frame, and counts as a connected client, see ClientCount.
+func Frames(ctx context.Context) iter.Seq[Frame] {
+	return func(yield func(Frame) bool) {
+		frames := srv.hub.register()
+		defer srv.hub.unregister(frames)
+
+		for {
+			select {
+			case f := <-frames:
+				if !yield(f.frame()) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-srv.stopped:
+				// the final frame is broadcast before the run loop stops.
+				select {
+				case f := <-frames:
+					yield(f.frame())
+				default:
+				}
+				return
+			}
+		}
+	}
+}
+
+// frame returns the Frame of the web frame f.
+func (f wplot) frame() Frame {
+	return Frame{N: f.N, Pi: f.Pi, Time: f.Time, Done: f.Done, PNG: f.png}
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// frameTTL is the duration the PNG images of the frames are served for,
+// with WithFrameURLs.
+const frameTTL = 10 * time.Second
+
+// WithFrameURLs serves the PNG images of the frames at cache-busting URLs,
+// "/frame/{run}/{seq}.png", rather than inlining them, base64-encoded, in
+// the frames sent to the web clients: the frames only carry the sequence
+// number of their image, which the page loads natively.
+// The run identifies the process, whose sequence numbers start at 1: the
+// images are cached forever by the browsers, even across restarts.
+// The images are served for 10 seconds, and the image of the last frame
+// until it is replaced.
+func WithFrameURLs() Option {
+	return func(cfg *config) {
+		cfg.urls = true
+	}
+}
+
+// frameStore holds the recent PNG images of the frames, by sequence number.
+type frameStore struct {
+	run  string // identifies the process in the URLs of the images
+	mu   sync.Mutex
+	seq  int
+	pngs map[int]storedPNG
+}
+
+type storedPNG struct {
+	png []byte
+	end time.Time // time after which the image is no longer served
+}
+
+// put stores the PNG image, removes the expired ones, and returns the
+// sequence number of the image.
+func (s *frameStore) put(png []byte) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for seq, v := range s.pngs {
+		if now.After(v.end) {
+			delete(s.pngs, seq)
+		}
+	}
+	if s.pngs == nil {
+		s.pngs = make(map[int]storedPNG)
+	}
+	s.seq++
+	s.pngs[s.seq] = storedPNG{png: png, end: now.Add(frameTTL)}
+	return s.seq
+}
+
+// get returns the PNG image with the sequence number seq, if still served.
+func (s *frameStore) get(seq int) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.pngs[seq]
+	if !ok || (seq != s.seq && time.Now().After(v.end)) {
+		return nil, false
+	}
+	return v.png, true
+}
+
+// frameHandle serves the PNG images of the frames, see WithFrameURLs.
+func (srv *server) frameHandle(w http.ResponseWriter, r *http.Request) {
+	run, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/frame/"), "/")
+	seq, err := strconv.Atoi(strings.TrimSuffix(name, ".png"))
+	if err != nil || !strings.HasSuffix(name, ".png") || run != srv.pngs.run {
+		http.NotFound(w, r)
+		return
+	}
+	png, ok := srv.pngs.get(seq)
+	if !ok {
+		http.Error(w, "mcpi: frame expired", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(png))
+}
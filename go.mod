@@ -1,10 +1,12 @@
 module github.com/master-pfa-info/mcpi
 
-go 1.20
+go 1.23
 
 require (
 	go-hep.org/x/hep v0.34.1
+	golang.org/x/image v0.13.0
 	golang.org/x/net v0.17.0
+	gonum.org/v1/gonum v0.14.0
 	gonum.org/v1/plot v0.14.0
 )
 
@@ -19,9 +21,7 @@ require (
 	github.com/gonuts/binary v0.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/exp v0.0.0-20231006140011-7918f672742d // indirect
-	golang.org/x/image v0.13.0 // indirect
 	golang.org/x/sync v0.4.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
-	gonum.org/v1/gonum v0.14.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
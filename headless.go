@@ -0,0 +1,42 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"context"
+	"fmt"
+)
+
+// RunHeadless runs a full simulation of n points drawn from sampler, and
+// returns the final estimate of Pi.
+//
+// RunHeadless uses its own private state, not the one of the web plot
+// server: nothing is rendered nor served, and the points are not retained.
+// This makes it a deterministic, one-call way to exercise the accumulation
+// of points, e.g. from tests, given a deterministic sampler.
+//
+// RunHeadless returns the context error if ctx is done before the end of
+// the simulation.
+func RunHeadless(ctx context.Context, sampler func() (float64, float64), n int) (pi float64, err error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("mcpi: invalid number of points (n=%d)", n)
+	}
+
+	srv := &server{cfg: newConfig()}
+	srv.cfg.frac = 0 // accumulate, but do not keep the points to draw
+	cfg := srv.config()
+
+	for i := 0; i < n; i++ {
+		if i%1024 == 0 {
+			err := ctx.Err()
+			if err != nil {
+				return 0, fmt.Errorf("mcpi: simulation interrupted after %d points: %w", i, err)
+			}
+		}
+		x, y := sampler()
+		srv.add(cfg, x, y, 1, -1)
+	}
+	return srv.estimate(), nil
+}
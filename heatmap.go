@@ -0,0 +1,129 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"go-hep.org/x/hep/hbook"
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/plotter"
+)
+
+// heatBins is the number of bins of the heatmap, along each axis.
+const heatBins = 50
+
+// WithHeatmap draws the density of the points as a heatmap, with the unit
+// circle, rather than the scatter of the inside and outside points.
+// The mode may also be switched at runtime from the "/config" endpoint.
+func WithHeatmap(on bool) Option {
+	return func(cfg *config) {
+		cfg.heatmap = on
+	}
+}
+
+// WithHeatmapSample bins, on each frame, a pseudo-random subsample of n of
+// the drawn points in the heatmap of WithHeatmap, or in the cells of
+// WithHexbin, rather than all of them, so the cost of binning does not grow
+// with the number of plotted points.
+// The subsample is drawn with replacement from a source seeded with seed on
+// each frame, and each sampled point weighs the number of drawn points over
+// n, so the scale of the colors matches the one of the full binning.
+//
+// The subsample is a noisy estimate of the density: the relative noise of
+// a bin holding a fraction f of the points is about 1/sqrt(f*n), regardless
+// of the number of points, so sparse bins flicker from frame to frame, and
+// the patterns smaller than the noise are lost. The estimate of Pi is not
+// affected.
+// A zero or negative n, the default, bins all the drawn points.
+func WithHeatmapSample(n int, seed int64) Option {
+	return func(cfg *config) {
+		cfg.heatSample = n
+		cfg.heatSeed = seed
+	}
+}
+
+// heatmap returns the plotters of the density of the points over the axes
+// range, and of the unit circle.
+func (srv *server) heatmap(cfg config, axes [2]float64) []plot.Plotter {
+	var ps []plot.Plotter
+	if srv.n > 0 {
+		h := hbook.NewH2D(heatBins, axes[0], axes[1], heatBins, axes[0], axes[1])
+		srv.density(cfg, h.Fill)
+		ps = append(ps, hplot.NewH2D(h, palette.Heat(12, 1)))
+	}
+	return append(ps, circle(axes)...)
+}
+
+// density calls fill with each drawn point and a unit weight, or with the
+// points of the subsample of WithHeatmapSample, and with the center of each
+// bin of the points beyond the memory budget and their number, see
+// SetMemoryBudget.
+func (srv *server) density(cfg config, fill func(x, y, w float64)) {
+	var (
+		pts = srv.drawnPoints()
+		m   = 0
+	)
+	for _, xy := range pts {
+		m += xy.Len()
+	}
+	switch k := cfg.heatSample; {
+	case k > 0 && k < m:
+		var (
+			rng = rand.New(rand.NewSource(cfg.heatSeed))
+			w   = float64(m) / float64(k)
+		)
+		for i := 0; i < k; i++ {
+			j := rng.Intn(m)
+			for _, xy := range pts {
+				if j < xy.Len() {
+					x, y := xy.XY(j)
+					fill(x, y, w)
+					break
+				}
+				j -= xy.Len()
+			}
+		}
+	default:
+		for _, xy := range pts {
+			for i := 0; i < xy.Len(); i++ {
+				x, y := xy.XY(i)
+				fill(x, y, 1)
+			}
+		}
+	}
+	if srv.binned != nil {
+		for _, bin := range srv.binned.Binning.Bins {
+			if w := bin.SumW(); w > 0 {
+				fill(bin.XMid(), bin.YMid(), w)
+			}
+		}
+	}
+}
+
+// circle returns the plotters of the halves of the unit circle over the axes:
+// the upper half, and the lower one with UseFullCircle.
+func circle(axes [2]float64) []plot.Plotter {
+	var ps []plot.Plotter
+	for _, sign := range []float64{+1, -1} {
+		if sign < 0 && axes[0] >= 0 {
+			break
+		}
+		sign := sign
+		half := plotter.NewFunction(func(x float64) float64 {
+			return sign * math.Sqrt(math.Max(0, 1-x*x))
+		})
+		half.XMin = math.Max(axes[0], -1)
+		half.XMax = math.Min(axes[1], +1)
+		half.Samples = 200
+		half.Color = color.Black
+		ps = append(ps, half)
+	}
+	return ps
+}
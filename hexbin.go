@@ -0,0 +1,108 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// WithHexbin draws the density of the points as hexagonal cells of the given
+// radius, the distance from their center to their corners in the units of
+// the axes, colored by their number of points, with the unit circle.
+// Hexagonal cells avoid the grid artifacts of the heatmap of WithHeatmap,
+// which they take precedence over.
+// A zero or negative radius disables the hexagonal cells, which is the
+// default.
+func WithHexbin(radius float64) Option {
+	return func(cfg *config) {
+		cfg.hexbin = radius
+	}
+}
+
+// hexbins returns the plotters of the density of the points, binned in
+// hexagonal cells of radius r, over the axes.
+func (srv *server) hexbins(cfg config, axes [2]float64, r float64) []plot.Plotter {
+	var ps []plot.Plotter
+	if srv.n > 0 {
+		h := hexbin{r: r, cells: make(map[[2]int]float64)}
+		srv.density(cfg, func(x, y, w float64) { h.fill(x, y, w) })
+		ps = append(ps, h)
+	}
+	return append(ps, circle(axes)...)
+}
+
+// hexbin is a plotter of the number of points in pointy-top hexagonal cells
+// of radius r, indexed by their axial coordinates.
+type hexbin struct {
+	r     float64
+	cells map[[2]int]float64
+}
+
+func (h hexbin) fill(x, y, w float64) {
+	// fractional axial coordinates, rounded to the nearest cell through
+	// their cube coordinates.
+	var (
+		q = (math.Sqrt(3)/3*x - y/3) / h.r
+		s = 2 * y / 3 / h.r
+		t = -q - s
+
+		rq, rs, rt = math.Round(q), math.Round(s), math.Round(t)
+		dq, ds, dt = math.Abs(rq - q), math.Abs(rs - s), math.Abs(rt - t)
+	)
+	switch {
+	case dq > ds && dq > dt:
+		rq = -rs - rt
+	case ds > dt:
+		rs = -rq - rt
+	}
+	h.cells[[2]int{int(rq), int(rs)}] += w
+}
+
+// center returns the center of the cell c.
+func (h hexbin) center(c [2]int) (x, y float64) {
+	q, s := float64(c[0]), float64(c[1])
+	return h.r * math.Sqrt(3) * (q + s/2), h.r * 3 / 2 * s
+}
+
+func (h hexbin) Plot(c draw.Canvas, plt *plot.Plot) {
+	var (
+		hi    float64
+		cells = make([][2]int, 0, len(h.cells))
+	)
+	for cell, w := range h.cells {
+		hi = math.Max(hi, w)
+		cells = append(cells, cell)
+	}
+	// draw the cells in a fixed order, as the anti-aliased edges of
+	// neighboring cells blend in drawing order, see WithDeterministicOutput.
+	slices.SortFunc(cells, func(a, b [2]int) int {
+		return cmp.Or(cmp.Compare(a[1], b[1]), cmp.Compare(a[0], b[0]))
+	})
+	var (
+		trX, trY = plt.Transforms(&c)
+		colors   = palette.Heat(12, 1).Colors()
+		poly     = make([]vg.Point, 6)
+	)
+	for _, cell := range cells {
+		w := h.cells[cell]
+		x, y := h.center(cell)
+		for i := range poly {
+			a := math.Pi/6 + float64(i)*math.Pi/3
+			poly[i] = vg.Point{
+				X: trX(x + h.r*math.Cos(a)),
+				Y: trY(y + h.r*math.Sin(a)),
+			}
+		}
+		i := int(w / hi * float64(len(colors)-1))
+		c.FillPolygon(colors[i], c.ClipPolygonXY(poly))
+	}
+}
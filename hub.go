@@ -0,0 +1,80 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// hub fans out frames to the connected web clients.
+//
+// Each client has a one-frame buffer: a client that is slower than the
+// frame rate only receives the latest frame.
+type hub struct {
+	mu      sync.Mutex
+	clients map[chan wplot]struct{}
+	last    *wplot       // last broadcast frame, sent to newly connected clients.
+	dropped atomic.Int64 // frames replaced before a client consumed them.
+
+	connected chan struct{} // notified when a client connects.
+}
+
+func newHub() *hub {
+	return &hub{
+		clients:   make(map[chan wplot]struct{}),
+		connected: make(chan struct{}, 1),
+	}
+}
+
+// register adds a new client to the hub.
+// The returned channel is primed with the last broadcast frame, if any.
+func (h *hub) register() chan wplot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan wplot, 1)
+	if h.last != nil {
+		ch <- *h.last
+	}
+	h.clients[ch] = struct{}{}
+
+	select {
+	case h.connected <- struct{}{}:
+	default:
+	}
+	return ch
+}
+
+// unregister removes the client from the hub.
+func (h *hub) unregister(ch chan wplot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, ch)
+}
+
+// broadcast sends the frame to all the connected clients, replacing
+// any frame a client has not consumed yet.
+func (h *hub) broadcast(frame wplot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.last = &frame
+	for ch := range h.clients {
+		select {
+		case <-ch:
+			h.dropped.Add(1)
+		default:
+		}
+		ch <- frame
+	}
+}
+
+// len returns the number of connected clients.
+func (h *hub) len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"math"
+	"time"
+)
+
+// SetIntakeRate limits the number of plotted points accepted per second,
+// e.g. to pace a live demo so that the scatter fills in at a watchable pace,
+// regardless of how fast the caller loops.
+// Excess points block their caller, see Plot, or are dropped with
+// WithIntakeDrop.
+// A zero or negative rate, the default, disables the limit.
+//
+// The limit applies to the intake of points, not to the rendering of frames.
+func SetIntakeRate(perSecond float64) {
+	srv.update(func(cfg *config) {
+		cfg.rate = perSecond
+	})
+}
+
+// WithIntakeDrop drops, rather than blocks, the points plotted in excess of
+// the intake rate (see SetIntakeRate).
+// Dropped points are counted as rejected (see Rejected).
+func WithIntakeDrop() Option {
+	return func(cfg *config) {
+		cfg.drop = true
+	}
+}
+
+// intake accounts for the (x,y,w,c) point, unless it is dropped in excess
+// of the intake rate.
+func (srv *server) intake(cfg config, v [4]float64, rate float64) {
+	if rate > 0 && !srv.bucket.take() {
+		srv.rejected++ // dropped in excess of the intake rate
+		srv.stats.dropped.Add(1)
+		return
+	}
+	srv.add(cfg, v[0], v[1], v[2], int(v[3]))
+}
+
+// bucket is a token bucket limiting the intake of points.
+// It holds at most a tenth of a second worth of points, and at least one.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill refills the bucket at rate tokens per second.
+func (b *bucket) refill(rate float64, now time.Time) {
+	burst := math.Max(1, rate/10)
+	switch {
+	case b.last.IsZero():
+		b.tokens = burst
+	default:
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+}
+
+// delay returns the time until a token is available, at rate tokens per second.
+func (b *bucket) delay(rate float64) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// take takes a token from the bucket, if any.
+func (b *bucket) take() bool {
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	defaultPing    = 30 * time.Second
+	defaultTimeout = 60 * time.Second
+)
+
+// WithKeepAlive pings the web clients every interval, and disconnects the
+// clients that have not answered for timeout, or to which a frame could not
+// be sent within timeout, e.g. the clients that vanished without closing
+// their connection.
+// The timeout should be larger than the interval. The defaults are 30s and
+// 60s.
+// A zero interval disables the pings, and a zero timeout the deadlines.
+func WithKeepAlive(interval, timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.ping = interval
+		cfg.timeout = timeout
+	}
+}
+
+// keepAlive wraps the websocket handler h, so the reads from the hijacked
+// connections extend their read deadline, see liveConn.
+func (srv *server) keepAlive(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := srv.config()
+		if cfg.ping <= 0 || cfg.timeout <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(hijacker{w, hj, cfg.timeout}, r)
+	})
+}
+
+// hijacker is a response writer whose hijacked connection is a liveConn.
+type hijacker struct {
+	http.ResponseWriter
+	hj      http.Hijacker
+	timeout time.Duration
+}
+
+func (w hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := w.hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	var r io.Reader = conn
+	if n := brw.Reader.Buffered(); n > 0 {
+		r = io.MultiReader(io.LimitReader(brw.Reader, int64(n)), conn)
+	}
+	lc := &liveConn{Conn: conn, r: r, timeout: w.timeout}
+	err = lc.extend()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("mcpi: could not set read deadline: %w", err)
+	}
+	return lc, bufio.NewReadWriter(bufio.NewReader(lc), bufio.NewWriter(lc)), nil
+}
+
+// liveConn is a connection whose read deadline is extended by timeout on
+// each read, so the reads fail once the peer has been silent for timeout.
+//
+// The websocket package answers and discards the control frames itself:
+// the pongs of a live client are only visible at the connection level.
+type liveConn struct {
+	net.Conn
+	r       io.Reader
+	timeout time.Duration
+}
+
+func (c *liveConn) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 && err == nil {
+		err = c.extend()
+	}
+	return n, err
+}
+
+func (c *liveConn) extend() error {
+	return c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+}
+
+// ping sends a ping frame to the web client.
+func ping(ws *websocket.Conn) error {
+	typ := ws.PayloadType
+	defer func() { ws.PayloadType = typ }()
+	ws.PayloadType = websocket.PingFrame
+	_, err := ws.Write(nil)
+	return err
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"math"
+	"strings"
+
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/text"
+	"gonum.org/v1/plot/vg"
+)
+
+// mathText is a text handler drawing the segments of text between "$" as
+// LaTeX math, and the other segments as plain text, see WithMathText.
+//
+// Contrary to text.Latex, the text may span several lines, and the plain
+// segments are laid out like text.Plain.
+type mathText struct {
+	plain text.Plain
+	tex   text.Latex
+}
+
+func (h mathText) Cache() *font.Cache {
+	return h.plain.Cache()
+}
+
+func (h mathText) Extents(fnt font.Font) font.Extents {
+	return h.plain.Extents(fnt)
+}
+
+func (h mathText) Lines(txt string) []string {
+	return h.plain.Lines(txt)
+}
+
+func (h mathText) Box(line string, fnt font.Font) (width, height, depth vg.Length) {
+	for _, seg := range segments(line) {
+		w, ht, d := h.handler(seg).Box(seg, fnt)
+		width += w
+		height = max(height, ht)
+		depth = max(depth, d)
+	}
+	return width, height, depth
+}
+
+func (h mathText) Draw(c vg.Canvas, txt string, sty text.Style, pt vg.Point) {
+	var (
+		e        = h.Extents(sty.Font)
+		linegap  = e.Height - e.Ascent - e.Descent
+		sin, cos = math.Sincos(sty.Rotation)
+		// y is the top of the current line, relative to pt and before the
+		// rotation, as for text.Style.Rectangle.
+		y = (vg.Length(sty.YAlign)+1)*sty.Height(txt) - (e.Height - e.Ascent)
+	)
+	for _, line := range h.Lines(txt) {
+		w, ht, d := h.Box(line, sty.Font)
+		var (
+			base = y - ht // baseline of the line
+			x    = vg.Length(sty.XAlign) * w
+		)
+		for _, seg := range segments(line) {
+			var (
+				hdlr = h.handler(seg)
+				s    = sty
+				dy   vg.Length
+			)
+			s.XAlign = text.XLeft
+			switch hdlr.(type) {
+			case text.Latex:
+				s.YAlign = text.YTop
+				_, sh, _ := hdlr.Box(seg, sty.Font)
+				dy = base + sh + (e.Height - e.Ascent)
+			default:
+				s.YAlign = text.YBottom
+				dy = base + e.Ascent - sty.Font.Size
+			}
+			// the handlers rotate their position along with the text.
+			hdlr.Draw(c, seg, s, vg.Point{
+				X: pt.X + x*vg.Length(cos) - dy*vg.Length(sin),
+				Y: pt.Y + x*vg.Length(sin) + dy*vg.Length(cos),
+			})
+			sw, _, _ := hdlr.Box(seg, sty.Font)
+			x += sw
+		}
+		y -= ht + d + linegap
+	}
+}
+
+// handler returns the handler of the segment seg.
+func (h mathText) handler(seg string) text.Handler {
+	if len(seg) > 1 && strings.HasPrefix(seg, "$") && strings.HasSuffix(seg, "$") {
+		return h.tex
+	}
+	return h.plain
+}
+
+// segments splits the line into its plain and math segments, the math
+// segments keeping their "$" delimiters. An unbalanced "$" is plain text.
+func segments(line string) []string {
+	var (
+		parts = strings.Split(line, "$")
+		segs  = make([]string, 0, len(parts))
+	)
+	for i, part := range parts {
+		switch {
+		case i%2 == 0:
+			if part != "" {
+				segs = append(segs, part)
+			}
+		case i == len(parts)-1:
+			segs = append(segs, "$"+part) // unbalanced.
+		default:
+			segs = append(segs, "$"+part+"$")
+		}
+	}
+	return segs
+}
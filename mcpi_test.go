@@ -0,0 +1,651 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer replaces the web plot server with a fresh one for the
+// duration of the test. The server is mounted, so it does not listen, it
+// renders no frame while no client is connected, and it quits quickly.
+func newTestServer(t testing.TB, opts ...Option) *server {
+	t.Helper()
+	prev := srv
+	srv = newServer()
+	srv.mounted = true
+	srv.update(func(cfg *config) {
+		cfg.log = slog.New(slog.NewTextHandler(io.Discard, nil))
+		cfg.nohint = true
+		cfg.lazy = true
+		cfg.quick = true
+		for _, opt := range opts {
+			opt(cfg)
+		}
+	})
+	t.Cleanup(func() {
+		Quit()
+		srv = prev
+	})
+	return srv
+}
+
+func TestPlotConcurrent(t *testing.T) {
+	newTestServer(t)
+
+	const (
+		producers = 16
+		points    = 1000
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < points; j++ {
+				Plot(0.5, 0.5)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, want := Result().N, producers*points; got != want {
+		t.Fatalf("invalid number of points: got=%d, want=%d", got, want)
+	}
+}
+
+func TestPlotFIFO(t *testing.T) {
+	srv := newTestServer(t)
+	srv.do(func() { srv.paused = true })
+
+	// queue the producers one after the other, while the points are left
+	// waiting, so their order is known.
+	const producers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Plot(float64(i)/producers, 0)
+		}()
+		for srv.stats.pending.Load() != int64(i+1) {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond) // let the producer block on its send.
+	}
+	srv.do(func() { srv.paused = false })
+	wg.Wait()
+
+	var xs []float64
+	srv.read(func() {
+		for i := 0; i < srv.in.Len(); i++ {
+			x, _ := srv.in.XY(i)
+			xs = append(xs, x)
+		}
+	})
+	if got := len(xs); got != producers {
+		t.Fatalf("invalid number of points: got=%d, want=%d", got, producers)
+	}
+	for i, x := range xs {
+		if got, want := x, float64(i)/producers; got != want {
+			t.Errorf("point %d: invalid order: got x=%v, want x=%v", i, got, want)
+		}
+	}
+}
+
+func TestDrawLens(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		pmaxIn, pmaxOut int
+		nin, nout       int
+		wantIn, wantOut int
+	}{
+		{"uncapped", 1000, 1000, 785, 215, 785, 215},
+		{"inside-capped", 100, 1000, 785, 215, 100, 27},
+		{"outside-capped", 1000, 100, 785, 215, 365, 100},
+		{"both-capped", 500, 100, 785, 215, 365, 100},
+		{"unlimited", -1, -1, 785, 215, 785, 215},
+		{"unlimited-inside", -1, 100, 785, 215, 365, 100},
+		{"unlimited-outside", 100, -1, 785, 215, 100, 27},
+		{"zero-limits", 0, 0, 785, 215, 0, 0},
+		{"zero-counts", 100, 100, 0, 0, 0, 0},
+		{"zero-inside", 100, 100, 0, 500, 0, 100},
+		{"zero-outside", 100, 100, 500, 0, 100, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			din, dout := drawLens(tc.pmaxIn, tc.pmaxOut, tc.nin, tc.nout)
+			if din != tc.wantIn || dout != tc.wantOut {
+				t.Fatalf(
+					"invalid drawn points: got=(%d, %d), want=(%d, %d)",
+					din, dout, tc.wantIn, tc.wantOut,
+				)
+			}
+			if din == 0 || dout == 0 {
+				return
+			}
+			// the drawn ratio matches the true one, up to the rounding.
+			var (
+				got  = float64(din) / float64(din+dout)
+				want = float64(tc.nin) / float64(tc.nin+tc.nout)
+				tol  = 1 / float64(min(din, dout))
+			)
+			if math.Abs(got-want) > tol {
+				t.Fatalf("invalid drawn ratio: got=%v, want=%v", got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkPlot(b *testing.B) {
+	pts := make([][2]float64, 1024)
+	for i := range pts {
+		pts[i] = [2]float64{float64(i%32) / 32, float64(i/32) / 32}
+	}
+	for _, bc := range []struct {
+		name  string
+		batch int
+	}{
+		{"point", 1},
+		{"batch", 256},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			newTestServer(b, WithBatchSize(bc.batch))
+			b.ResetTimer()
+			for i := 0; i < b.N; i += len(pts) {
+				err := Plots(pts[:min(len(pts), b.N-i)])
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestStrictDomain(t *testing.T) {
+	newTestServer(t, WithStrictDomain())
+
+	// sample uniformly a square larger than the domain: the points of the
+	// domain are uniformly distributed, and the others are rejected.
+	var (
+		rng      = rand.New(rand.NewSource(1))
+		pts      = make([][2]float64, 100000)
+		accepted = 0
+	)
+	for i := range pts {
+		x, y := 2*rng.Float64()-0.5, 2*rng.Float64()-0.5
+		if 0 <= x && x <= 1 && 0 <= y && y <= 1 {
+			accepted++
+		}
+		pts[i] = [2]float64{x, y}
+	}
+	err := Plots(pts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := Rejected(), len(pts)-accepted; got != want {
+		t.Fatalf("invalid number of rejected points: got=%d, want=%d", got, want)
+	}
+	if got, want := Result().N, accepted; got != want {
+		t.Fatalf("invalid number of points: got=%d, want=%d", got, want)
+	}
+	// about 5 standard deviations of the estimate, for 25000 points.
+	if got, tol := Estimate(), 0.05; math.Abs(got-math.Pi) > tol {
+		t.Fatalf("biased estimate: got=%v, want=%v±%v", got, math.Pi, tol)
+	}
+}
+
+func TestPlotWeighted(t *testing.T) {
+	newTestServer(t)
+
+	// importance sampling: x is drawn with the density q(x) = 2(1+x)/3 over
+	// [0,1], by inversion of its CDF, and weighted by 1/q(x).
+	const n = 50000
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		var (
+			x = math.Sqrt(1+3*rng.Float64()) - 1
+			y = rng.Float64()
+			q = 2 * (1 + x) / 3
+		)
+		PlotWeighted(x, y, 1/q)
+	}
+
+	if got, want := Result().N, n; got != want {
+		t.Fatalf("invalid number of points: got=%d, want=%d", got, want)
+	}
+	// about 5 standard deviations of the estimate.
+	if got, tol := Estimate(), 0.05; math.Abs(got-math.Pi) > tol {
+		t.Fatalf("invalid estimate: got=%v, want=%v±%v", got, math.Pi, tol)
+	}
+}
+
+func TestPlotWeightedInvalid(t *testing.T) {
+	newTestServer(t)
+
+	PlotWeighted(0.5, 0.5, 2)
+	for _, w := range []float64{0, -1, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		PlotWeighted(0.5, 0.5, w)
+	}
+
+	if got, want := Rejected(), 5; got != want {
+		t.Fatalf("invalid number of rejected points: got=%d, want=%d", got, want)
+	}
+	if got, want := Estimate(), 4.0; got != want {
+		t.Fatalf("invalid estimate: got=%v, want=%v", got, want)
+	}
+}
+
+func TestPlotFraction(t *testing.T) {
+	srv := newTestServer(t)
+	SetPlotFraction(0.1)
+
+	const n = 50000
+	var (
+		rng    = rand.New(rand.NewSource(1))
+		inside = 0
+	)
+	for i := 0; i < n; i++ {
+		x, y := rng.Float64(), rng.Float64()
+		if x*x+y*y < 1 {
+			inside++
+		}
+		Plot(x, y)
+	}
+
+	// all the points are accounted for, drawn or not.
+	if got, want := Estimate(), 4*float64(inside)/n; got != want {
+		t.Fatalf("invalid estimate: got=%v, want=%v", got, want)
+	}
+	var drawn int
+	srv.read(func() { drawn = srv.in.Len() + srv.out.Len() })
+	if drawn < n/20 || drawn > n/5 {
+		t.Fatalf("invalid number of drawn points: got=%d, want about %d", drawn, n/10)
+	}
+}
+
+func TestFrameWithoutPoints(t *testing.T) {
+	srv := newTestServer(t)
+
+	if got := Estimate(); !math.IsNaN(got) {
+		t.Fatalf("invalid estimate: got=%v, want=NaN", got)
+	}
+
+	var (
+		frames = srv.hub.register()
+		title  string
+		err    error
+	)
+	defer srv.hub.unregister(frames)
+	srv.do(func() {
+		p, perr := srv.plot(srv.config())
+		if perr != nil {
+			err = perr
+			return
+		}
+		title = p.Title.Text
+		srv.render()
+	})
+	if err != nil {
+		t.Fatalf("could not create plot: %+v", err)
+	}
+	if got, want := title, "n = 0\nπ = n/a"; got != want {
+		t.Fatalf("invalid title:\ngot= %q\nwant=%q", got, want)
+	}
+
+	frame := <-frames
+	raw, err := json.Marshal(frame)
+	if err != nil {
+		t.Fatalf("could not marshal frame: %+v", err)
+	}
+	var v map[string]any
+	err = json.Unmarshal(raw, &v)
+	if err != nil {
+		t.Fatalf("could not unmarshal frame: %+v", err)
+	}
+	if pi, ok := v["pi"]; !ok || pi != nil {
+		t.Fatalf("invalid pi: got=%v, want=null", pi)
+	}
+	if got := v["n"]; got != 0.0 {
+		t.Fatalf("invalid n: got=%v, want=0", got)
+	}
+}
+
+func TestInclusiveBoundary(t *testing.T) {
+	// points exactly on the unit circle, and just beyond it.
+	var (
+		circle = [][2]float64{{1, 0}, {0, 1}}
+		beyond = [2]float64{1 + 1e-12, 0}
+	)
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want float64 // fraction of the inside points
+	}{
+		{"exclusive", nil, 0},
+		{"inclusive", []Option{WithInclusiveBoundary(0)}, 2.0 / 3},
+		{"inclusive-eps", []Option{WithInclusiveBoundary(1e-9)}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			newTestServer(t, tc.opts...)
+			err := Plots(append(circle, beyond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Fraction(); got != tc.want {
+				t.Fatalf("invalid fraction of inside points: got=%v, want=%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandlerNotFound(t *testing.T) {
+	newTestServer(t)
+	ts := httptest.NewServer(Handler())
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{"/", http.StatusOK},
+		{"/bogus", http.StatusNotFound},
+		{"/frame/bogus", http.StatusNotFound},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tc.path)
+			if err != nil {
+				t.Fatalf("could not get %q: %+v", tc.path, err)
+			}
+			defer resp.Body.Close()
+			if got := resp.StatusCode; got != tc.want {
+				t.Fatalf("invalid status: got=%d, want=%d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadCSVNonFinite(t *testing.T) {
+	newTestServer(t)
+	for _, tc := range []struct {
+		name string
+		csv  string
+	}{
+		{"nan", "x,y\n0.1,0.2\nnan,0.5\n"},
+		{"inf", "x,y\n0.1,0.2\n0.5,inf\n"},
+		{"-inf", "x,y\n0.1,0.2\n-Inf,0.5\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := LoadCSV(strings.NewReader(tc.csv))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), "mcpi: line 3: could not parse point"; !strings.HasPrefix(got, want) {
+				t.Fatalf("invalid error:\ngot= %q\nwant=%q...", got, want)
+			}
+		})
+	}
+}
+
+func TestFrameFailed(t *testing.T) {
+	srv := newTestServer(t)
+
+	frames := srv.hub.register()
+	defer srv.hub.unregister(frames)
+	// a NaN point can not be plotted: its frame is logged, counted and
+	// skipped, instead of stopping the program.
+	srv.do(func() {
+		srv.in.push(math.NaN(), 0.5)
+		srv.n++
+		srv.render()
+	})
+	if got := Diagnostics().FramesFailed; got != 1 {
+		t.Fatalf("invalid number of failed frames: got=%d, want=1", got)
+	}
+	if got := Diagnostics().FramesRendered; got != 0 {
+		t.Fatalf("invalid number of rendered frames: got=%d, want=0", got)
+	}
+	select {
+	case frame := <-frames:
+		t.Fatalf("unexpected frame: n=%d", frame.N)
+	default:
+	}
+}
+
+func TestFrameURLs(t *testing.T) {
+	srv := newTestServer(t, WithFrameURLs())
+	ts := httptest.NewServer(Handler())
+	defer ts.Close()
+
+	frames := srv.hub.register()
+	defer srv.hub.unregister(frames)
+	srv.do(func() { srv.render() })
+	frame := <-frames
+
+	// the URLs of the images of another process are not served.
+	for _, tc := range []struct {
+		run  string
+		want int
+	}{
+		{srv.pngs.run, http.StatusOK},
+		{"other", http.StatusNotFound},
+	} {
+		url := ts.URL + "/frame/" + tc.run + "/" + strconv.Itoa(frame.Seq) + ".png"
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("could not get %q: %+v", url, err)
+		}
+		resp.Body.Close()
+		if got := resp.StatusCode; got != tc.want {
+			t.Fatalf("invalid status of %q: got=%d, want=%d", url, got, tc.want)
+		}
+	}
+}
+
+func TestPreviewTrails(t *testing.T) {
+	srv := newTestServer(t, WithTrails(10))
+	Plot(0.1, 0.1)
+	Plot(0.9, 0.9)
+
+	// the tracked estimates are redrawn, even when n did not change.
+	var (
+		before, after []byte
+		err           error
+	)
+	srv.do(func() {
+		cfg := srv.config()
+		srv.track(cfg)
+		before, err = srv.png(cfg)
+		if err != nil {
+			return
+		}
+		srv.track(cfg)
+		srv.track(cfg)
+		after, err = srv.png(cfg)
+	})
+	if err != nil {
+		t.Fatalf("could not render preview: %+v", err)
+	}
+	if bytes.Equal(before, after) {
+		t.Fatalf("stale preview: the sparkline was not redrawn")
+	}
+}
+
+func TestResultWithoutPoints(t *testing.T) {
+	newTestServer(t)
+
+	raw, err := json.Marshal(Result())
+	if err != nil {
+		t.Fatalf("could not marshal result: %+v", err)
+	}
+	var v map[string]any
+	err = json.Unmarshal(raw, &v)
+	if err != nil {
+		t.Fatalf("could not unmarshal result: %+v", err)
+	}
+	for _, k := range []string{"pi", "absError"} {
+		if got, ok := v[k]; !ok || got != nil {
+			t.Fatalf("invalid %s: got=%v, want=null", k, got)
+		}
+	}
+
+	var r Summary
+	err = json.Unmarshal(raw, &r)
+	if err != nil {
+		t.Fatalf("could not unmarshal summary: %+v", err)
+	}
+	if !math.IsNaN(r.Pi) || !math.IsNaN(r.AbsError) {
+		t.Fatalf("invalid summary: got pi=%v, absError=%v, want NaN", r.Pi, r.AbsError)
+	}
+}
+
+func TestLazySkipped(t *testing.T) {
+	srv := newTestServer(t)
+	Plot(0.5, 0.5)
+	Flush()
+	skipped := Diagnostics().FramesSkipped
+
+	// the frame skipped while no client is connected is not skipped again
+	// on each tick.
+	time.Sleep(5 * srv.tick)
+	if got := Diagnostics().FramesSkipped; got != skipped {
+		t.Fatalf("invalid number of skipped frames: got=%d, want=%d", got, skipped)
+	}
+}
+
+func TestConfigStopped(t *testing.T) {
+	newTestServer(t)
+	ts := httptest.NewServer(Handler())
+	defer ts.Close()
+	Quit()
+
+	resp, err := http.Post(ts.URL+"/config", "application/json", strings.NewReader(`{"precision": 3}`))
+	if err != nil {
+		t.Fatalf("could not post configuration: %+v", err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("invalid status: got=%d, want=%d", got, want)
+	}
+}
+
+func TestMergeTooMany(t *testing.T) {
+	var milestones []int
+	srv := newTestServer(t)
+	srv.update(func(cfg *config) {
+		cfg.onMilestone = func(n int, pi float64) { milestones = append(milestones, n) }
+		cfg.leibniz = true
+		cfg.trails = 10
+		cfg.pmaxIn, cfg.pmaxOut = 0, 0 // no need to draw the merged points.
+	})
+	ts := httptest.NewServer(Handler())
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		total int
+		want  int
+	}{
+		{maxPoints - 10, http.StatusNoContent},
+		{10, http.StatusNoContent},
+		{1, http.StatusBadRequest},
+	} {
+		body := fmt.Sprintf(`{"inside": 0, "total": %d}`, tc.total)
+		resp, err := http.Post(ts.URL+"/merge", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("could not merge %d points: %+v", tc.total, err)
+		}
+		resp.Body.Close()
+		if got := resp.StatusCode; got != tc.want {
+			t.Fatalf("invalid status of %d points: got=%d, want=%d", tc.total, got, tc.want)
+		}
+	}
+
+	var pi float64
+	srv.do(func() {
+		srv.track(srv.config())
+		pi = srv.lsum.at(srv.n)
+	})
+	if got, want := Result().N, maxPoints; got != want {
+		t.Fatalf("invalid number of points: got=%d, want=%d", got, want)
+	}
+	if got, want := len(milestones), 15; got != want {
+		t.Fatalf("invalid number of milestones: got=%d, want=%d", got, want)
+	}
+	if got, tol := pi, 1e-15; math.Abs(got-math.Pi) > tol {
+		t.Fatalf("invalid Leibniz estimate: got=%v, want=%v±%v", got, math.Pi, tol)
+	}
+}
+
+func TestServeUnixQuit(t *testing.T) {
+	newTestServer(t)
+	path := filepath.Join(t.TempDir(), "mcpi.sock")
+	err := ServeUnix(path)
+	if err != nil {
+		t.Fatalf("could not serve on %q: %+v", path, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("missing socket: %+v", err)
+	}
+
+	Quit()
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("socket not removed: %+v", err)
+	}
+}
+
+func TestHandlerRedirect(t *testing.T) {
+	newTestServer(t)
+	mux := http.NewServeMux()
+	mux.Handle("/mcpi/", http.StripPrefix("/mcpi", Handler()))
+	mux.Handle("/mcpi", http.StripPrefix("/mcpi", Handler()))
+
+	for _, tc := range []struct {
+		target string
+		want   string
+	}{
+		{"/mcpi", "/mcpi/"},
+		{"/mcpi?x=1", "/mcpi/?x=1"},
+	} {
+		t.Run(tc.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			if got, want := w.Code, http.StatusMovedPermanently; got != want {
+				t.Fatalf("invalid status: got=%d, want=%d", got, want)
+			}
+			if got := w.Header().Get("Location"); got != tc.want {
+				t.Fatalf("invalid location: got=%q, want=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotWithoutPoints(t *testing.T) {
+	newTestServer(t)
+
+	img, err := Snapshot()
+	if err != nil {
+		t.Fatalf("could not snapshot: %+v", err)
+	}
+	if img.Bounds().Empty() {
+		t.Fatalf("empty snapshot")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"go-hep.org/x/hep/hbook"
+)
+
+// SetMemoryBudget bounds to about n bytes the storage of the drawn points.
+//
+// Points are retained, to be drawn as scatters, until their storage reaches
+// the budget. The following points are then binned into a fixed-size
+// histogram, and the frames switch to the heatmap of the density of all the
+// points (see WithHeatmap), so the memory stays bounded however many points
+// are plotted. The switch is logged, and is not reverted when the budget is
+// raised.
+// The budget applies to all the drawn points: the plotted ones, including
+// the points of PlotCategory, and the sampled points of AddCounts. Reserve
+// grows the storage within the budget.
+// All the points are accounted for in the estimate of Pi.
+// A zero or negative budget, the default, retains all the points.
+func SetMemoryBudget(n int64) {
+	srv.update(func(cfg *config) {
+		cfg.budget = n
+	})
+}
+
+// retained returns the size, in bytes, of the drawn points, not counting the
+// spare capacity of their storage.
+func (srv *server) retained() int64 {
+	const (
+		xy = 16 // size of a point, plotter.XY
+		w  = 8  // size of a weight
+	)
+	n := srv.in.size() + srv.out.size() + srv.burned.size()
+	n += int64(len(srv.inW)+len(srv.outW)) * w
+	for _, pts := range srv.cats {
+		n += int64(len(pts)) * xy
+	}
+	return n
+}
+
+// affordable returns how many of n more inside or outside points can be
+// stored within the memory budget.
+func (srv *server) affordable(cfg config, n int) int {
+	if cfg.budget <= 0 {
+		return n
+	}
+	per := int64(16) // size of a point, plotter.XY
+	if srv.in.use32 {
+		per = 8
+	}
+	if srv.inW != nil {
+		per += 8 // size of a weight
+	}
+	avail := max(0, (cfg.budget-srv.retained())/per)
+	if avail < int64(n) {
+		return int(avail)
+	}
+	return n
+}
+
+// bin bins the point (x,y) once the memory budget is reached, and returns
+// whether it did.
+func (srv *server) bin(cfg config, x, y float64) bool {
+	if srv.binned == nil {
+		if cfg.budget <= 0 || srv.retained() < cfg.budget {
+			return false
+		}
+		lo, hi := cfg.domain[0], cfg.domain[1]
+		srv.binned = hbook.NewH2D(heatBins, lo, hi, heatBins, lo, hi)
+		srv.logger().Info(
+			"memory budget reached, binning the points",
+			"budget", cfg.budget, "n", srv.n,
+		)
+	}
+	srv.binned.Fill(x, y, 1)
+	return true
+}
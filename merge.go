@@ -0,0 +1,64 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Merge combines the results of another Monte-Carlo process, e.g. a worker
+// node of a cluster, into the plotted ones: total points, of which inside
+// fell inside the disk.
+// The combined estimate of Pi is then 4*sum(inside)/sum(total).
+//
+// Workers may also POST their results to the "/merge" endpoint of the
+// aggregating server, as {"inside": 785, "total": 1000} with the
+// "application/json" content type.
+//
+// See AddCounts for how the merged points are drawn.
+func Merge(inside, total int) {
+	AddCounts(inside, total)
+}
+
+// mergeHandle merges the results POSTed by workers.
+func (srv *server) mergeHandle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !jsonBody(w, r) {
+		return
+	}
+
+	var counts struct {
+		Inside int `json:"inside"`
+		Total  int `json:"total"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&counts)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("mcpi: could not decode counts: %v", err), http.StatusBadRequest)
+		return
+	}
+	if counts.Inside < 0 || counts.Total < counts.Inside {
+		http.Error(w, fmt.Sprintf("mcpi: invalid counts (inside=%d, total=%d)", counts.Inside, counts.Total), http.StatusBadRequest)
+		return
+	}
+
+	switch err := srv.count(counts.Inside, counts.Total); {
+	case errors.Is(err, ErrClosed):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
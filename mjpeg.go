@@ -0,0 +1,137 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// streamHandle serves the frames as a MJPEG stream, a multipart/x-mixed-replace
+// response of JPEG images that browsers display as a live-updating image,
+// without websockets nor scripts. See the page served by streamPageHandle.
+//
+// The viewers of the stream are web clients like the others: they receive
+// the frames of the hub, only the latest one when they are slower than the
+// frame rate, and the stream ends after the final frame.
+func (srv *server) streamHandle(w http.ResponseWriter, r *http.Request) {
+	frames := srv.hub.register()
+	defer srv.hub.unregister(frames)
+
+	var (
+		cfg = srv.config()
+		rc  = http.NewResponseController(w)
+		mw  = multipart.NewWriter(w)
+	)
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mw.Boundary())
+	w.Header().Set("Cache-Control", "no-cache")
+	// send the headers right away, the first frame may take a while.
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	for {
+		select {
+		case frame := <-frames:
+			jpg, err := srv.jpeg(frame)
+			if err != nil {
+				srv.logger().Error("error encoding frame", "err", err)
+				return
+			}
+			if cfg.timeout > 0 {
+				_ = rc.SetWriteDeadline(time.Now().Add(cfg.timeout))
+			}
+			part, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":   {"image/jpeg"},
+				"Content-Length": {strconv.Itoa(len(jpg))},
+			})
+			if err == nil {
+				_, err = part.Write(jpg)
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				srv.logger().Error("error sending frame", "err", err)
+				return
+			}
+			if frame.Done {
+				_ = mw.Close()
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// jpeg returns the JPEG image of the frame, drawn over a white background.
+// The frames without image, with WithClientRendering, are rendered.
+func (srv *server) jpeg(frame wplot) ([]byte, error) {
+	var (
+		raw = frame.png
+		err error
+	)
+	if raw == nil {
+		srv.read(func() { raw, err = srv.png(srv.config()) })
+		if err != nil {
+			return nil, err
+		}
+	}
+	src, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("mcpi: could not decode frame: %w", err)
+	}
+	img := image.NewRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, img, nil)
+	if err != nil {
+		return nil, fmt.Errorf("mcpi: could not encode frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// streamPageHandle serves a minimal plot page displaying the MJPEG stream,
+// for casual viewers whose browser or proxy does not support websockets.
+func (srv *server) streamPageHandle(w http.ResponseWriter, r *http.Request) {
+	err := streamPageTmpl.Execute(w, struct{ Name string }{srv.config().name})
+	if err != nil {
+		srv.logger().Error("error executing page template", "err", err)
+	}
+	select {
+	case srv.wait <- 1:
+	default:
+	}
+}
+
+const streamPage = `
+<html>
+	<head>
+		<title>{{with .Name}}{{.}} - {{end}}Monte Carlo</title>
+	</head>
+
+	<body>
+		<div id="content">
+			<p style="text-align:center;">
+				<img id="plot" src="stream.mjpeg" alt="Not Available"></img>
+			</p>
+		</div>
+	</body>
+</html>
+`
+
+var streamPageTmpl = template.Must(template.New("stream-page").Parse(streamPage))
@@ -0,0 +1,116 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// SaveSmallMultiples writes to w, as a PNG image, a grid of the plots of the
+// state at each of the provided numbers of points, e.g. to show the
+// convergence at a glance.
+//
+// The points are not retained in their plotting order: the plot with n
+// points draws the first drawn inside and outside points, split according
+// to the estimate of Pi of the convergence series at n points, or at the
+// last frame before n (see ConvergenceCSV).
+// Each plot has the size of the frames, see SetPixelSize.
+func SaveSmallMultiples(w io.Writer, atCounts []int) error {
+	if len(atCounts) == 0 {
+		return errors.New("mcpi: no number of points")
+	}
+	var (
+		png []byte
+		err error
+	)
+	srv.read(func() { png, err = srv.multiples(srv.config(), atCounts) })
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(png)
+	if err != nil {
+		return fmt.Errorf("mcpi: could not write small multiples: %w", err)
+	}
+	return nil
+}
+
+// multiples renders the grid of the plots of the state at each of the
+// provided numbers of points.
+func (srv *server) multiples(cfg config, counts []int) ([]byte, error) {
+	var (
+		cols  = int(math.Ceil(math.Sqrt(float64(len(counts)))))
+		rows  = (len(counts) + cols - 1) / cols
+		w, h  = vg.Length(cols) * cfg.size[0], vg.Length(rows) * cfg.size[1]
+		tiles = hplot.NewTiledPlot(draw.Tiles{Rows: rows, Cols: cols})
+	)
+	err := cfg.fits(w, h, cfg.dpi)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.trails = 0 // the sparkline tracks the current state.
+	for i := range tiles.Plots {
+		if i >= len(counts) {
+			tiles.Plots[i] = nil
+			continue
+		}
+		n := counts[i]
+		if n <= 0 || n > srv.n {
+			return nil, fmt.Errorf("mcpi: invalid number of points (n=%d, max=%d)", n, srv.n)
+		}
+		p, err := srv.at(n).plot(cfg)
+		if err != nil {
+			return nil, err
+		}
+		tiles.Plots[i] = p
+	}
+
+	canvas := vgimg.NewWith(
+		vgimg.UseWH(w, h),
+		vgimg.UseDPI(cfg.dpi),
+		vgimg.UseBackgroundColor(color.White),
+	)
+	tiles.Draw(draw.New(canvas))
+	return renderCanvas(canvas)
+}
+
+// at returns the reconstructed state with n points, see SaveSmallMultiples.
+func (srv *server) at(n int) *server {
+	pi := srv.estimate()
+	for _, pt := range srv.series {
+		if pt.N > n {
+			break
+		}
+		pi = pt.Pi
+	}
+	var (
+		win = pi / 4 * float64(n)
+		// the fraction of the points that are drawn, see SetPlotFraction.
+		drawn = float64(srv.in.Len()+srv.out.Len()) / float64(srv.n)
+		nin   = min(srv.in.Len(), int(math.Round(win*drawn)))
+		nout  = min(srv.out.Len(), int(math.Round((float64(n)-win)*drawn)))
+	)
+	sub := &server{
+		in:   srv.in.slice(nin),
+		out:  srv.out.slice(nout),
+		n:    n,
+		win:  win,
+		wsum: float64(n),
+	}
+	if srv.inW != nil {
+		sub.inW = srv.inW[:nin]
+		sub.outW = srv.outW[:nout]
+	}
+	return sub
+}
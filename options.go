@@ -0,0 +1,269 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"gonum.org/v1/plot/vg"
+)
+
+// Option configures the web plot server.
+type Option func(cfg *config)
+
+// Configure applies the provided options to the web plot server.
+func Configure(opts ...Option) {
+	srv.update(func(cfg *config) {
+		for _, opt := range opts {
+			opt(cfg)
+		}
+	})
+}
+
+// WithAllowedOrigins allows cross-origin requests to the web plot server
+// from the provided origins (e.g. "https://example.com").
+// The "*" origin allows requests from any origin.
+//
+// By default, only same-origin requests are allowed.
+func WithAllowedOrigins(origins []string) Option {
+	return func(cfg *config) {
+		cfg.origins = make([]string, len(origins))
+		for i, o := range origins {
+			cfg.origins[i] = strings.TrimSuffix(o, "/")
+		}
+	}
+}
+
+// WithRenderWhenObserved defers the rendering of frames while no web client
+// is connected.
+// Points are still accumulated, and a frame is rendered as soon as a client
+// connects. The final frame is always rendered on Quit.
+func WithRenderWhenObserved() Option {
+	return func(cfg *config) {
+		cfg.lazy = true
+	}
+}
+
+// WithRecencyFade draws recently plotted points larger and more opaque than
+// older ones, so the eye can follow where sampling is happening.
+// This is purely cosmetic.
+func WithRecencyFade() Option {
+	return func(cfg *config) {
+		cfg.fade = true
+	}
+}
+
+// WithStrictDomain rejects the plotted points that lie outside of the
+// sampled domain, [0,1]x[0,1] by default or [-1,1]x[-1,1] with UseFullCircle,
+// as well as points with NaN coordinates.
+//
+// Rejected points are not accounted for, neither in the number of points n
+// nor in the estimate of Pi. See Rejected.
+// By default, all points are accepted.
+func WithStrictDomain() Option {
+	return func(cfg *config) {
+		cfg.strict = true
+	}
+}
+
+// WithWeightedRadius sizes the drawn points by their weight (see PlotWeighted),
+// so the area of a point is proportional to its weight relative to the mean.
+func WithWeightedRadius() Option {
+	return func(cfg *config) {
+		cfg.wradius = true
+	}
+}
+
+// WithPointRadius sets the radius of the drawn points. The default is 0.5pt.
+func WithPointRadius(r vg.Length) Option {
+	return func(cfg *config) {
+		cfg.radius = r
+	}
+}
+
+// WithColors sets the colors of the inside and outside points.
+// The defaults are red and blue.
+func WithColors(inside, outside color.Color) Option {
+	return func(cfg *config) {
+		cfg.colors = [2]color.Color{inside, outside}
+	}
+}
+
+// WithPrecision sets the number of decimal digits of the displayed estimate
+// of Pi. A negative precision, the default, displays the shortest
+// representation of the estimate.
+func WithPrecision(digits int) Option {
+	return func(cfg *config) {
+		cfg.prec = digits
+	}
+}
+
+// WithQuickShutdown skips the final frame, and the delay letting web clients
+// display it, when no client is connected and nothing is recorded.
+// This makes shutdown fast and cheap for headless runs.
+func WithQuickShutdown() Option {
+	return func(cfg *config) {
+		cfg.quick = true
+	}
+}
+
+// WithErrorAnnotation annotates the plot with the absolute error of the
+// estimate, |estimate-π|, and its number of correct decimal digits, as
+// counted by CorrectDigits.
+func WithErrorAnnotation() Option {
+	return func(cfg *config) {
+		cfg.abserr = true
+	}
+}
+
+// WithAutoscale fits the axes of the plot to the extent of the plotted
+// points, rather than to the sampled domain, so that points sampled outside
+// of the domain are not clipped.
+func WithAutoscale() Option {
+	return func(cfg *config) {
+		cfg.autoscale = true
+	}
+}
+
+// WithoutWaitHint suppresses the hint logged when points are plotted before
+// any web client connected, e.g. for headless runs.
+func WithoutWaitHint() Option {
+	return func(cfg *config) {
+		cfg.nohint = true
+	}
+}
+
+// WithBatchSize processes up to n of the plotted points waiting to be
+// accounted for as a batch: the decision to emit a new frame is taken once
+// after the batch, based on its final number of points, rather than after
+// each point. Larger batches trade reactivity for throughput.
+// By default, or with n <= 1, points are processed one at a time.
+func WithBatchSize(n int) Option {
+	return func(cfg *config) {
+		cfg.batch = n
+	}
+}
+
+// WithInclusiveBoundary counts the points on the unit circle, x²+y²=1, as
+// inside, as well as the points within eps of it, x²+y²<=1+eps, e.g. for
+// gridded inputs whose points may lie exactly on the circle.
+//
+// By default, points on the circle are outside: a point is inside if and
+// only if x²+y²<1.
+func WithInclusiveBoundary(eps float64) Option {
+	return func(cfg *config) {
+		cfg.inclusive = true
+		cfg.eps = eps
+	}
+}
+
+// WithCategoryColors sets the colors of the categories of points plotted
+// with PlotCategory: the points of the category c are drawn in colors[c].
+// By default, the two categories are drawn in the colors of the inside and
+// outside points.
+func WithCategoryColors(colors ...color.Color) Option {
+	return func(cfg *config) {
+		cfg.cats = append([]color.Color(nil), colors...)
+	}
+}
+
+// categories returns the colors of the categories of points.
+func (cfg config) categories() []color.Color {
+	if len(cfg.cats) == 0 {
+		return cfg.colors[:]
+	}
+	return cfg.cats
+}
+
+// WithLegend draws, or not, a legend of the colors of the inside and
+// outside points. By default, no legend is drawn.
+// See WithLegendLabels to customize the labels.
+func WithLegend(on bool) Option {
+	return func(cfg *config) {
+		cfg.legend = on
+	}
+}
+
+// WithLegendLabels sets the labels of the inside and outside points in the
+// legend, e.g. for localized demos, and draws the legend.
+// By default, the labels describe the inside test, e.g. "inside (x²+y²<1)".
+func WithLegendLabels(inside, outside string) Option {
+	return func(cfg *config) {
+		cfg.legend = true
+		cfg.labels = [2]string{inside, outside}
+	}
+}
+
+// legends returns the legend labels of the inside and outside points.
+func (cfg config) legends() (inside, outside string) {
+	if cfg.labels != [2]string{} {
+		return cfg.labels[0], cfg.labels[1]
+	}
+	var (
+		sq = "x²+y²"
+		op = "<"
+	)
+	if cfg.ascii {
+		sq = "x^2+y^2"
+	}
+	if cfg.inclusive {
+		op = "<="
+		if !cfg.ascii {
+			op = "≤"
+		}
+	}
+	return fmt.Sprintf("inside (%s%s1)", sq, op), "outside"
+}
+
+// WithTransparentBackground renders the frames on a transparent background,
+// e.g. to overlay them on a colored slide, instead of an opaque white one.
+func WithTransparentBackground() Option {
+	return func(cfg *config) {
+		cfg.transparent = true
+	}
+}
+
+// WithCorrectDigits shows, in the title of the plot, the number of correct
+// decimal digits of the estimate of Pi, e.g. "π = 3.1412 (~3 digits)".
+// See CorrectDigits.
+func WithCorrectDigits() Option {
+	return func(cfg *config) {
+		cfg.digits = true
+	}
+}
+
+// WithReferenceMarkers draws markers, labeled with their coordinates, at the
+// corners of the sampled square and at the midpoint of the arc of the circle,
+// e.g. to check the geometry of a custom domain or size.
+// The markers are not drawn by default.
+func WithReferenceMarkers(on bool) Option {
+	return func(cfg *config) {
+		cfg.refs = on
+	}
+}
+
+// WithYAxisDown draws the y axis growing downward, with its minimum at the
+// top of the plot, e.g. to composite the frames into image-coordinate
+// canvases. The points, the boundary arc and the heatmaps are flipped
+// consistently, and the estimate of Pi is unchanged.
+// The frames of WithClientRendering are not flipped.
+// The y axis grows upward by default.
+func WithYAxisDown(down bool) Option {
+	return func(cfg *config) {
+		cfg.ydown = down
+	}
+}
+
+// allowOrigin returns whether cross-origin requests from origin are allowed.
+func (cfg config) allowOrigin(origin string) bool {
+	for _, o := range cfg.origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// pngCache holds the last rendered PNG image, so it can be shared between
+// the web clients and the preview endpoint.
+type pngCache struct {
+	mu  sync.Mutex
+	key pngKey
+	png []byte
+}
+
+// pngKey identifies a rendered state.
+type pngKey struct {
+	n       int // number of points
+	inside  int // number of inside points
+	version int // configuration version
+	gen     int // generation of the drawn state, see server.gen
+}
+
+func (c *pngCache) get(key pngKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.png == nil || c.key != key {
+		return nil, false
+	}
+	return c.png, true
+}
+
+func (c *pngCache) set(key pngKey, png []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.key = key
+	c.png = png
+}
+
+// last returns the last rendered PNG image, if any, and its key.
+func (c *pngCache) last() ([]byte, pngKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.png, c.key
+}
+
+// png returns the PNG image of the current state, rendering it if needed.
+func (srv *server) png(cfg config) ([]byte, error) {
+	key := pngKey{n: srv.n, inside: srv.in.Len(), version: cfg.version, gen: srv.gen}
+	if png, ok := srv.cache.get(key); ok {
+		return png, nil
+	}
+	p, err := srv.plot(cfg)
+	if err != nil {
+		return nil, err
+	}
+	w, h := cfg.size[0], cfg.size[1]
+	dpi := srv.resolution(cfg, w, h)
+	if cfg.fits(w, h, dpi) != nil {
+		// the pixel budget was lowered after SetPixelSize.
+		w, h, dpi = defaultSize, defaultSize, vgimg.DefaultDPI
+	}
+	srv.frame.dpi = dpi
+	png, err := renderImg(p, w, h, dpi)
+	if err != nil {
+		return nil, err
+	}
+	srv.cache.set(key, png)
+	return png, nil
+}
+
+// BuildPlot returns the plot of the current state, as drawn on each frame:
+// the scatters of the inside and outside points, the grid and the title.
+//
+// Callers may customize the returned plot, e.g. add annotations, change its
+// scales or attach it to a subplot, before rendering it themselves.
+// The plot holds copies of the points: later points are not drawn on it.
+func BuildPlot() (*hplot.Plot, error) {
+	var (
+		p   *hplot.Plot
+		err error
+	)
+	srv.read(func() { p, err = srv.plot(srv.config()) })
+	return p, err
+}
+
+// Snapshot renders the current state as an image, without encoding it,
+// for callers compositing or analyzing the frames.
+// Before any point is plotted, the image is the one of the frames, with an
+// estimate of "n/a".
+func Snapshot() (image.Image, error) {
+	var (
+		img image.Image
+		err error
+	)
+	srv.read(func() {
+		var (
+			cfg = srv.config()
+			p   *hplot.Plot
+		)
+		err = cfg.fits(cfg.size[0], cfg.size[1], cfg.dpi)
+		if err != nil {
+			return
+		}
+		p, err = srv.plot(cfg)
+		if err != nil {
+			return
+		}
+		img = drawImg(p, cfg.size[0], cfg.size[1], cfg.dpi).Image()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// previewHandle serves the current frame as a PNG image.
+// The frame is rendered if the cached one is out of date, so the endpoint
+// works even when no web client is connected.
+func (srv *server) previewHandle(w http.ResponseWriter, r *http.Request) {
+	// once the run loop has finished, the cache holds the final frame.
+	var err error
+	_ = srv.do(func() { _, err = srv.png(srv.config()) })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	png, key := srv.cache.last()
+	if png == nil {
+		http.Error(w, "mcpi: no frame rendered", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%d-%d-%d-%d"`, key.n, key.inside, key.version, key.gen))
+	http.ServeContent(w, r, "preview.png", time.Time{}, bytes.NewReader(png))
+}
+
+// renderHandle serves a one-shot rendering of the current state, with the
+// size (e.g. "40cm") and resolution (in dots per inch) provided by the size
+// and dpi query parameters.
+func (srv *server) renderHandle(w http.ResponseWriter, r *http.Request) {
+	var (
+		size = defaultSize
+		dpi  = vgimg.DefaultDPI
+		err  error
+	)
+	if v := r.FormValue("size"); v != "" {
+		size, err = vg.ParseLength(v)
+		if err != nil || size <= 0 {
+			http.Error(w, fmt.Sprintf("mcpi: invalid size %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.FormValue("dpi"); v != "" {
+		dpi, err = strconv.Atoi(v)
+		if err != nil || dpi <= 0 {
+			http.Error(w, fmt.Sprintf("mcpi: invalid dpi %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = srv.config().fits(size, size, dpi)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var png []byte
+	srv.read(func() {
+		var p *hplot.Plot
+		p, err = srv.plot(srv.config())
+		if err != nil {
+			return
+		}
+		png, err = renderImg(p, size, size, dpi)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(png)
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Record writes each subsequently emitted frame to w, as newline-delimited JSON.
+// Each frame holds the number of points, the estimate of Pi, the emission time
+// and the base64-encoded PNG image.
+//
+// Recording stops after the first write error, or when Record is called with
+// a nil writer.
+func Record(w io.Writer) {
+	srv.update(func(cfg *config) {
+		switch w {
+		case nil:
+			cfg.rec = nil
+		default:
+			cfg.rec = json.NewEncoder(w)
+		}
+	})
+}
+
+// record writes the frame to the current recorder and tee, if any.
+func (srv *server) record(frame wplot) {
+	enc := srv.config().rec
+	if enc == nil && srv.tee == nil {
+		return
+	}
+	if frame.Plot == "" && frame.png != nil {
+		// the image is served at its own URL, see WithFrameURLs.
+		frame.Plot = base64.StdEncoding.EncodeToString(frame.png)
+		frame.Seq = 0
+	}
+	if srv.tee != nil {
+		srv.tee.send(frame)
+	}
+	if enc == nil {
+		return
+	}
+	err := enc.Encode(frame)
+	if err == nil {
+		return
+	}
+	srv.logger().Error("error recording frame, recording stopped", "err", err)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.cfg.rec == enc {
+		srv.cfg.rec = nil
+	}
+}
+
+// Replay reads frames previously written by Record from r and sends them
+// to the connected web clients.
+// The delay between two frames is the one recorded, divided by speed:
+// a speed of 2 replays the session twice as fast.
+func Replay(r io.Reader, speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("mcpi: invalid replay speed %v", speed)
+	}
+
+	var (
+		dec  = json.NewDecoder(r)
+		prev time.Time
+	)
+	for {
+		var frame wplot
+		err := dec.Decode(&frame)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("mcpi: could not decode frame: %w", err)
+		}
+		if frame.Plot != "" {
+			// the image of the frame, for the MJPEG stream and Frames.
+			frame.png, err = base64.StdEncoding.DecodeString(frame.Plot)
+			if err != nil {
+				return fmt.Errorf("mcpi: could not decode frame image: %w", err)
+			}
+		}
+		if !prev.IsZero() && frame.Time.After(prev) {
+			time.Sleep(time.Duration(float64(frame.Time.Sub(prev)) / speed))
+		}
+		prev = frame.Time
+		srv.hub.broadcast(frame)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Summary is the summary of the simulation, e.g. to assert in CI that the
+// estimate of a seeded simulation is within tolerance.
+type Summary struct {
+	N        int           `json:"n"`        // number of accounted points, see Estimate
+	Inside   float64       `json:"inside"`   // number of inside points, or the sum of their weights
+	Outside  float64       `json:"outside"`  // number of outside points, or the sum of their weights
+	Pi       float64       `json:"pi"`       // estimate of Pi, 4*Inside/(Inside+Outside)
+	AbsError float64       `json:"absError"` // absolute error of the estimate, |Pi-π|
+	Elapsed  time.Duration `json:"elapsed"`  // runtime since Wait, SetClock or the package initialization, in nanoseconds
+}
+
+// MarshalJSON encodes Pi and AbsError as null until a point has been
+// accounted for, as JSON has no NaN.
+func (r Summary) MarshalJSON() ([]byte, error) {
+	type summary Summary // without the MarshalJSON method
+	v := struct {
+		summary
+		Pi       *float64 `json:"pi"`
+		AbsError *float64 `json:"absError"`
+	}{summary: summary(r)}
+	if !math.IsNaN(r.Pi) {
+		v.Pi = &r.Pi
+		v.AbsError = &r.AbsError
+	}
+	return json.Marshal(v)
+}
+
+func (r *Summary) UnmarshalJSON(data []byte) error {
+	type summary Summary // without the UnmarshalJSON method
+	v := struct {
+		*summary
+		Pi       *float64 `json:"pi"`
+		AbsError *float64 `json:"absError"`
+	}{summary: (*summary)(r)}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	r.Pi, r.AbsError = math.NaN(), math.NaN()
+	if v.Pi != nil {
+		r.Pi = *v.Pi
+	}
+	if v.AbsError != nil {
+		r.AbsError = *v.AbsError
+	}
+	return nil
+}
+
+// Result returns the summary of the current state of the simulation.
+// Pi and AbsError are NaN until a point has been accounted for, and encoded
+// as null in JSON.
+func Result() Summary {
+	var r Summary
+	srv.read(func() { r = srv.result() })
+	return r
+}
+
+// WriteResult writes the summary of the current state of the simulation to
+// w, as JSON, e.g. at the end of a headless run:
+//
+//	mcpi.Quit()
+//	err := mcpi.WriteResult(os.Stdout)
+//
+// WriteResult returns an error until a point has been accounted for, as the
+// estimate is NaN.
+func WriteResult(w io.Writer) error {
+	r := Result()
+	if math.IsNaN(r.Pi) {
+		return errors.New("mcpi: no point accounted for")
+	}
+	err := json.NewEncoder(w).Encode(r)
+	if err != nil {
+		return fmt.Errorf("mcpi: could not write result: %w", err)
+	}
+	return nil
+}
+
+// result returns the summary of the simulation.
+// result must be called from the run loop, see server.read.
+func (srv *server) result() Summary {
+	pi := srv.estimate()
+	return Summary{
+		N:        srv.n,
+		Inside:   srv.win,
+		Outside:  srv.wsum - srv.win,
+		Pi:       pi,
+		AbsError: math.Abs(pi - math.Pi),
+		Elapsed:  srv.elapsed(),
+	}
+}
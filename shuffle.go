@@ -0,0 +1,75 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"math/rand"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// WithShuffledDraw draws, when the draw limits cap the drawn points (see
+// SetDrawLimits), a pseudo-random subset of the retained points rather than
+// the first ones, so the drawn points are a fair sample of the whole run
+// rather than of its start.
+// The subset is drawn from a source seeded with seed on each frame: the
+// same points draw the same subset. The drawn points keep their plotting
+// order, e.g. for WithRecencyFade.
+//
+// Only the rendering of the frames is affected: all the points are still
+// accounted for in the estimate of Pi, and the first points are still sent
+// to the web clients with WithClientRendering.
+func WithShuffledDraw(seed int64) Option {
+	return func(cfg *config) {
+		cfg.shuffle = true
+		cfg.seed = seed
+	}
+}
+
+// drawn returns the n points of pts to draw, and their indices in pts, or
+// nil for the first n points.
+func (cfg config) drawn(pts xys, n int) (plotter.XYer, []int) {
+	if !cfg.shuffle || n >= pts.Len() {
+		return pts.slice(n), nil
+	}
+	// selection sampling, see Knuth's TAOCP Vol. 2, 3.4.2, algorithm S.
+	var (
+		rng = rand.New(rand.NewSource(cfg.seed))
+		idx = make([]int, 0, n)
+		m   = pts.Len()
+	)
+	for i := 0; i < m && len(idx) < n; i++ {
+		if rng.Intn(m-i) < n-len(idx) {
+			idx = append(idx, i)
+		}
+	}
+	return subset{pts, idx}, idx
+}
+
+// pick returns the elements of vs at the indices idx, or vs if idx is nil.
+func pick(vs []float64, idx []int) []float64 {
+	if idx == nil {
+		return vs
+	}
+	out := make([]float64, len(idx))
+	for i, j := range idx {
+		out[i] = vs[j]
+	}
+	return out
+}
+
+// subset is the subset of points of xys at the indices idx.
+type subset struct {
+	xys
+	idx []int
+}
+
+func (s subset) Len() int {
+	return len(s.idx)
+}
+
+func (s subset) XY(i int) (x, y float64) {
+	return s.xys.XY(s.idx[i])
+}
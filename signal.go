@@ -0,0 +1,37 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals installs a handler of the SIGINT and SIGTERM signals, e.g.
+// for standalone demo programs interrupted with Ctrl-C: on reception, the
+// web plot server is stopped as with Quit, emitting the final frame and
+// logging the summary, its listener is closed and the program exits.
+//
+// Signals are not handled by default, so that programs using the package
+// keep control over them.
+func HandleSignals() {
+	srv.signals.Do(func() {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-sigc
+			signal.Stop(sigc)
+			srv.logger().Info("signal received", "signal", sig)
+			Quit()
+			err := Stop()
+			if err != nil {
+				srv.logger().Error("error stopping web-server", "err", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}()
+	})
+}
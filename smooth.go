@@ -0,0 +1,52 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+// WithSmoothing tracks an exponentially-weighted moving average of the
+// estimate of Pi, updated with each point with the smoothing factor alpha,
+// in (0,1]: the smaller alpha, the smoother the average.
+// The average is drawn along the estimates with WithTrails.
+//
+// The smoothing is disabled by default, or with a zero alpha.
+// See SmoothedEstimate.
+func WithSmoothing(alpha float64) Option {
+	return func(cfg *config) {
+		cfg.alpha = alpha
+	}
+}
+
+// SmoothedEstimate returns the moving average of the estimate of Pi, see
+// WithSmoothing, or the current estimate if the smoothing is disabled.
+func SmoothedEstimate() float64 {
+	var pi float64
+	srv.read(func() {
+		switch {
+		case srv.ewma.ok && srv.config().smoothing():
+			pi = srv.ewma.pi
+		default:
+			pi = srv.estimate()
+		}
+	})
+	return pi
+}
+
+// smoothing returns whether the moving average of the estimate is enabled.
+func (cfg config) smoothing() bool {
+	return 0 < cfg.alpha && cfg.alpha <= 1
+}
+
+// smooth updates the moving average with the current estimate.
+func (srv *server) smooth(cfg config) {
+	if !cfg.smoothing() || srv.n == 0 {
+		return
+	}
+	pi := srv.estimate()
+	if !srv.ewma.ok {
+		srv.ewma.pi = pi
+		srv.ewma.ok = true
+		return
+	}
+	srv.ewma.pi += cfg.alpha * (pi - srv.ewma.pi)
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// Count holds the counts of the accounted points.
+type Count struct {
+	N       int     // number of accounted points
+	Inside  float64 // number of inside points, or the sum of their weights
+	Outside float64 // number of outside points, or the sum of their weights
+}
+
+// Counts returns the sequence of the counts of the accounted points, for
+// lightweight numeric observers that do not need the frames:
+//
+//	for c := range mcpi.Counts(ctx) {
+//		log.Printf("n=%d, pi=%v", c.N, 4*c.Inside/(c.Inside+c.Outside))
+//	}
+//
+// The sequence starts with the current counts, and yields the counts after
+// each batch of accounted points (see WithBatchSize) and each call to
+// AddCounts, without rendering any frame. An observer slower than the
+// updates only receives the latest counts.
+// The sequence ends when ctx is cancelled or once the final counts have
+// been yielded, when the server has stopped.
+func Counts(ctx context.Context) iter.Seq[Count] {
+	return func(yield func(Count) bool) {
+		var (
+			counts chan Count
+			cur    Count
+		)
+		// subscribe on the run loop, so the current counts precede the
+		// published ones.
+		srv.read(func() {
+			counts = srv.counts.subscribe()
+			cur = srv.counted()
+		})
+		defer srv.counts.unsubscribe(counts)
+
+		if !yield(cur) {
+			return
+		}
+		for {
+			select {
+			case c := <-counts:
+				if !yield(c) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-srv.stopped:
+				select {
+				case c := <-counts:
+					yield(c)
+				default:
+				}
+				return
+			}
+		}
+	}
+}
+
+// counted returns the current counts.
+func (srv *server) counted() Count {
+	return Count{N: srv.n, Inside: srv.win, Outside: srv.wsum - srv.win}
+}
+
+// counters fans out the counts to the observers of Counts.
+//
+// Like the hub, each observer has a one-count buffer holding the latest
+// counts.
+type counters struct {
+	mu   sync.Mutex
+	subs map[chan Count]struct{}
+}
+
+func (c *counters) subscribe() chan Count {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subs == nil {
+		c.subs = make(map[chan Count]struct{})
+	}
+	ch := make(chan Count, 1)
+	c.subs[ch] = struct{}{}
+	return ch
+}
+
+func (c *counters) unsubscribe(ch chan Count) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subs, ch)
+}
+
+// publish sends the counts to all the observers, replacing any counts an
+// observer has not consumed yet.
+func (c *counters) publish(cnt Count) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch := range c.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- cnt
+	}
+}
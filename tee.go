@@ -0,0 +1,90 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+)
+
+// teeBuffer is the number of frames buffered for a slow tee writer.
+const teeBuffer = 16
+
+// Tee writes each subsequently emitted frame to w, in the format of Record,
+// so the session can be captured live and replayed later with Replay.
+//
+// Contrary to Record, the frames are written to w by a separate goroutine:
+// a slow writer never delays the simulation nor the web clients. The frames
+// emitted while the last 16 frames are still buffered are dropped.
+// The buffered frames are written, and w is flushed if it has a
+// Flush() error method (e.g. a *bufio.Writer), when the tee is replaced or
+// stopped, and by Quit.
+//
+// The tee stops after the first write error, or when Tee is called with a
+// nil writer. Tee is a no-op once the server has stopped.
+func Tee(w io.Writer) {
+	srv.do(func() {
+		if srv.tee != nil {
+			srv.tee.close()
+			srv.tee = nil
+		}
+		if w != nil {
+			srv.tee = newTee(w, srv.logger())
+		}
+	})
+}
+
+// tee writes frames to a writer in its own goroutine.
+type tee struct {
+	frames chan wplot
+	done   chan struct{}
+}
+
+func newTee(w io.Writer, logger *slog.Logger) *tee {
+	t := &tee{
+		frames: make(chan wplot, teeBuffer),
+		done:   make(chan struct{}),
+	}
+	go t.run(w, logger)
+	return t
+}
+
+func (t *tee) run(w io.Writer, logger *slog.Logger) {
+	defer close(t.done)
+	var (
+		enc = json.NewEncoder(w)
+		err error
+	)
+	for frame := range t.frames {
+		if err != nil {
+			continue // drain the frames until closed.
+		}
+		err = enc.Encode(frame)
+		if err != nil {
+			logger.Error("error writing frame, tee stopped", "err", err)
+		}
+	}
+	if f, ok := w.(interface{ Flush() error }); ok && err == nil {
+		err = f.Flush()
+		if err != nil {
+			logger.Error("error flushing tee", "err", err)
+		}
+	}
+}
+
+// send sends the frame to the tee, or drops it if the buffer is full.
+func (t *tee) send(frame wplot) {
+	select {
+	case t.frames <- frame:
+	default:
+	}
+}
+
+// close writes the buffered frames and flushes the writer.
+func (t *tee) close() {
+	close(t.frames)
+	<-t.done
+}
@@ -0,0 +1,44 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot"
+)
+
+// WithTicks customizes the ticks of both axes: n is the suggested number of
+// major ticks, format the fmt format of their labels (e.g. "%.2f"), and
+// minor whether to draw minor ticks between them.
+// A zero n or an empty format selects them automatically.
+// By default, the ticks of gonum/plot are drawn.
+func WithTicks(n int, format string, minor bool) Option {
+	return func(cfg *config) {
+		cfg.ticks = ticks{
+			major: hplot.Ticks{N: n, Format: format},
+			minor: minor,
+		}
+	}
+}
+
+// ticks marks the axes with major ticks, and optionally minor ticks.
+type ticks struct {
+	major hplot.Ticks
+	minor bool
+}
+
+func (t ticks) Ticks(min, max float64) []plot.Tick {
+	ts := t.major.Ticks(min, max)
+	if t.minor {
+		return ts
+	}
+	major := ts[:0]
+	for _, tck := range ts {
+		if !tck.IsMinor() {
+			major = append(major, tck)
+		}
+	}
+	return major
+}
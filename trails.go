@@ -0,0 +1,197 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// WithTrails overlays, in a corner of the scatter plot, a sparkline of the
+// estimates of Pi of the last k frames, so viewers can see the estimate
+// jittering towards π.
+// A zero or negative k disables the sparkline, which is the default.
+func WithTrails(k int) Option {
+	return func(cfg *config) {
+		cfg.trails = k
+	}
+}
+
+// ring is a fixed-capacity ring buffer of estimates of Pi.
+type ring struct {
+	vs  []point
+	beg int // index of the oldest value, once the buffer is full
+}
+
+func newRing(n int) *ring {
+	return &ring{vs: make([]point, 0, n)}
+}
+
+func (r *ring) push(v point) {
+	if len(r.vs) < cap(r.vs) {
+		r.vs = append(r.vs, v)
+		return
+	}
+	r.vs[r.beg] = v
+	r.beg = (r.beg + 1) % len(r.vs)
+}
+
+// xys returns the values, oldest first, indexed by their position.
+func (r *ring) xys() plotter.XYs {
+	xys := make(plotter.XYs, len(r.vs))
+	for i := range xys {
+		xys[i].X = float64(i)
+		xys[i].Y = r.at(i).Pi
+	}
+	return xys
+}
+
+// at returns the i-th value, oldest first.
+func (r *ring) at(i int) point {
+	return r.vs[(r.beg+i)%len(r.vs)]
+}
+
+// track records the estimate of the current frame, if trails are enabled.
+func (srv *server) track(cfg config) {
+	switch {
+	case srv.n == 0:
+		return // no estimate yet
+	case cfg.trails <= 0:
+		srv.trail = nil
+		srv.strail = nil
+		srv.ltrail = nil
+		return
+	case srv.trail == nil || cap(srv.trail.vs) != cfg.trails:
+		srv.trail = newRing(cfg.trails)
+		srv.strail = nil
+		srv.ltrail = nil
+	}
+	srv.trail.push(point{N: srv.n, Pi: srv.estimate()})
+	srv.gen++ // the sparkline changed.
+
+	switch {
+	case !cfg.leibniz:
+		srv.ltrail = nil
+	case srv.ltrail == nil:
+		srv.ltrail = newRing(cfg.trails)
+	}
+	if srv.ltrail != nil {
+		srv.ltrail.push(point{N: srv.n, Pi: srv.lsum.at(srv.n)})
+	}
+
+	switch {
+	case !cfg.smoothing() || !srv.ewma.ok:
+		srv.strail = nil
+		return
+	case srv.strail == nil:
+		srv.strail = newRing(cfg.trails)
+	}
+	srv.strail.push(point{N: srv.n, Pi: srv.ewma.pi})
+}
+
+// sparkline returns the plot of the tracked estimates, of their moving
+// average with WithSmoothing, of their convergence with
+// WithConvergenceTolerance, and of the Leibniz series with
+// EnableLeibnizComparison.
+func (srv *server) sparkline(cfg config) (*hplot.Plot, error) {
+	p := hplot.New()
+	err := cfg.style(p)
+	if err != nil {
+		return nil, err
+	}
+	p.BackgroundColor = color.White
+	p.HideX()
+	p.Y.Tick.Label.Font.Size = 6
+	p.Y.Padding = 0
+
+	line, err := hplot.NewLine(srv.trail.xys())
+	if err != nil {
+		return nil, fmt.Errorf("mcpi: could not create sparkline: %w", err)
+	}
+	line.Color = color.Black
+
+	ref := hplot.HLine(math.Pi, nil, nil)
+	ref.Line.Color = color.Gray{128}
+	ref.Line.Dashes = []vg.Length{vg.Points(2), vg.Points(2)}
+	// name the reference line, at the left of the sparkline, keeping it in
+	// view.
+	name := hplot.NewLabel(0, math.Pi, cfg.pi(), hplot.WithLabelTextStyle(draw.TextStyle{
+		Color:   ref.Line.Color,
+		Font:    p.Y.Tick.Label.Font,
+		Handler: p.TextHandler,
+	}))
+
+	p.Add(ref, name, line)
+
+	for _, v := range []struct {
+		r *ring
+		c color.Color
+	}{
+		{srv.strail, color.RGBA{255, 128, 0, 255}},
+		{srv.ltrail, color.RGBA{128, 0, 192, 255}},
+	} {
+		if v.r == nil || len(v.r.vs) < 2 {
+			continue
+		}
+		xys := v.r.xys()
+		// align the estimates on the last tracked estimates.
+		for i := range xys {
+			xys[i].X += float64(len(srv.trail.vs) - len(xys))
+		}
+		line, err := hplot.NewLine(xys)
+		if err != nil {
+			return nil, fmt.Errorf("mcpi: could not create sparkline: %w", err)
+		}
+		line.Color = v.c
+		p.Add(line)
+	}
+
+	if n, ok := srv.convergedAt(cfg.tol); cfg.tol > 0 && ok {
+		// mark the first tracked frame within the tolerance, if tracked.
+		for i := range srv.trail.vs {
+			if srv.trail.at(i).N < n {
+				continue
+			}
+			if i > 0 || srv.trail.at(0).N == n {
+				mark := hplot.VLine(float64(i), nil, nil)
+				mark.Line.Color = color.RGBA{0, 160, 0, 255}
+				p.Add(mark)
+			}
+			break
+		}
+		p.Add(hplot.NewLabel(
+			0.05, 0.05, fmt.Sprintf("converged at n≈%d", n),
+			hplot.WithLabelNormalized(true),
+			hplot.WithLabelTextStyle(draw.TextStyle{
+				Color:   color.Black,
+				Font:    p.Y.Tick.Label.Font,
+				Handler: p.TextHandler,
+			}),
+		))
+	}
+	return p, nil
+}
+
+// inset is a plotter drawing a plot in the top-right corner of the data
+// area of another plot, covering the given fraction of its width and height.
+type inset struct {
+	p    *hplot.Plot
+	frac float64
+}
+
+func (in inset) Plot(c draw.Canvas, _ *plot.Plot) {
+	var (
+		w = vg.Length(1-in.frac) * (c.Max.X - c.Min.X)
+		h = vg.Length(1-in.frac) * (c.Max.Y - c.Min.Y)
+	)
+	in.p.Draw(draw.Crop(c, w, 0, h, 0))
+}
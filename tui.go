@@ -0,0 +1,159 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	tuiCols = 64 // width of the terminal plot, in characters
+	tuiRows = 32 // height of the terminal plot, in characters
+)
+
+// ServeTUI renders the frames in the terminal, on os.Stdout, as a braille
+// plot of the drawn points redrawn in place, e.g. to run the demo over SSH
+// without a browser.
+//
+// The terminal plot is redrawn at each emitted frame, with the cadence of
+// the web frames, by a separate goroutine: a slow terminal drops frames
+// rather than delaying the simulation.
+// Each character cell is drawn in red if most of its points are inside
+// points, and in blue otherwise. The points of the categories of
+// PlotCategory are not drawn.
+// ServeTUI returns immediately, and is a no-op if the terminal plot is
+// already served or once the server has stopped. Quit returns once the
+// final frame has been drawn.
+func ServeTUI() {
+	srv.do(func() {
+		if srv.term == nil {
+			srv.term = newTerminal(os.Stdout, srv.logger())
+		}
+	})
+}
+
+// terminal writes the terminal plots to a writer in its own goroutine.
+type terminal struct {
+	plots chan []byte // latest terminal plot not yet written
+	done  chan struct{}
+}
+
+func newTerminal(w io.Writer, logger *slog.Logger) *terminal {
+	t := &terminal{
+		plots: make(chan []byte, 1),
+		done:  make(chan struct{}),
+	}
+	go t.run(w, logger)
+	return t
+}
+
+func (t *terminal) run(w io.Writer, logger *slog.Logger) {
+	defer close(t.done)
+	_, err := io.WriteString(w, "\x1b[2J") // clear the screen.
+	for plot := range t.plots {
+		if err != nil {
+			continue // drain the plots until closed.
+		}
+		_, err = w.Write(plot)
+	}
+	if err != nil {
+		logger.Error("could not write terminal plot", "err", err)
+	}
+}
+
+// send sends the terminal plot to the writer, replacing the plot not yet
+// written, if any.
+// send must only be called from the run loop.
+func (t *terminal) send(plot []byte) {
+	select {
+	case <-t.plots:
+	default:
+	}
+	t.plots <- plot
+}
+
+// close writes the last terminal plot.
+func (t *terminal) close() {
+	close(t.plots)
+	<-t.done
+}
+
+// braille returns the terminal plot of the drawn points, with the status
+// line of the frame, as ANSI escape sequences drawing from the top left of
+// the terminal.
+//
+// Each character is a braille pattern of 2x4 dots.
+func (srv *server) braille(cfg config, frame wplot) []byte {
+	type cell struct {
+		dots    rune // braille dots, see dot
+		in, out int  // number of inside and outside points
+	}
+	var (
+		cells     [tuiRows][tuiCols]cell
+		axes      = srv.axes(cfg)
+		w         = axes[1] - axes[0]
+		nin, nout = drawLens(cfg.pmaxIn, cfg.pmaxOut, srv.in.Len(), srv.out.Len())
+	)
+	for k, pts := range []xys{srv.in.slice(nin), srv.out.slice(nout)} {
+		for i := 0; i < pts.Len(); i++ {
+			x, y := pts.XY(i)
+			var (
+				u = int((x - axes[0]) / w * 2 * tuiCols)
+				v = int((axes[1] - y) / w * 4 * tuiRows)
+			)
+			if u < 0 || u > 2*tuiCols || v < 0 || v > 4*tuiRows {
+				continue
+			}
+			u, v = min(u, 2*tuiCols-1), min(v, 4*tuiRows-1)
+			c := &cells[v/4][u/2]
+			c.dots |= dot(u%2, v%4)
+			switch k {
+			case 0:
+				c.in++
+			default:
+				c.out++
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("\x1b[H") // move to the top left.
+	for _, row := range cells {
+		for _, c := range row {
+			switch {
+			case c.dots == 0:
+				buf.WriteByte(' ')
+			case c.in >= c.out:
+				fmt.Fprintf(buf, "\x1b[31m%c\x1b[0m", 0x2800+c.dots)
+			default:
+				fmt.Fprintf(buf, "\x1b[34m%c\x1b[0m", 0x2800+c.dots)
+			}
+		}
+		buf.WriteString("\x1b[K\n")
+	}
+	pi := "n/a"
+	if frame.N > 0 {
+		pi = cfg.format(frame.Pi)
+	}
+	fmt.Fprintf(buf, "n = %d, %s = %s", frame.N, cfg.pi(), pi)
+	if frame.Done {
+		buf.WriteString(" (simulation complete)")
+	}
+	buf.WriteString("\x1b[K\n\x1b[J")
+	return buf.Bytes()
+}
+
+// dot returns the braille dot at column u, in [0,1], and row v, in [0,3],
+// of a character cell.
+func dot(u, v int) rune {
+	if v == 3 {
+		return 0x40 << u
+	}
+	return 1 << (3*u + v)
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 The master-pfa-info Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mcpi
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// WithClientRendering delegates the rendering of frames to the web client.
+//
+// Instead of PNG images, frames carry the (x,y) points to draw, capped by the
+// draw limits. The plot page then loads a WebAssembly client that draws them
+// on a HTML canvas.
+// The client assets, main.wasm and wasm_exec.js, are served from dir:
+//
+//	$> GOOS=js GOARCH=wasm go build -o ./www/main.wasm github.com/master-pfa-info/mcpi/cmd/mcpi-wasm
+//	$> cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" ./www/
+func WithClientRendering(dir string) Option {
+	return func(cfg *config) {
+		cfg.assets = dir
+	}
+}
+
+// points returns the inside and outside points to draw, for client-side rendering.
+func points(cfg config, in, out xys) (pin, pout [][2]float64) {
+	nin, nout := drawLens(cfg.pmaxIn, cfg.pmaxOut, in.Len(), out.Len())
+	pin = make([][2]float64, nin)
+	for i := range pin {
+		pin[i][0], pin[i][1] = in.XY(i)
+	}
+	pout = make([][2]float64, nout)
+	for i := range pout {
+		pout[i][0], pout[i][1] = out.XY(i)
+	}
+	return pin, pout
+}
+
+// assetsHandle serves the WebAssembly client assets.
+func (srv *server) assetsHandle(w http.ResponseWriter, r *http.Request) {
+	dir := srv.config().assets
+	if dir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	http.StripPrefix("/wasm/", http.FileServer(http.Dir(dir))).ServeHTTP(w, r)
+}
+
+const wasmPage = `
+<html>
+	<head>
+		<title>{{with .Name}}{{.}} - {{end}}Monte Carlo</title>
+		<script type="text/javascript" src="wasm/wasm_exec.js"></script>
+		<script type="text/javascript">
+		window.onload = function() {
+			const go = new Go();
+			WebAssembly.instantiateStreaming(
+				fetch("wasm/main.wasm"), go.importObject
+			).then(function(r) {
+				go.run(r.instance);
+			});
+		};
+		</script>
+	</head>
+
+	<body>
+		<div id="content">
+			<p style="text-align:center;">
+				<canvas id="plot" width="756" height="756"></canvas>
+			</p>
+		</div>
+	</body>
+</html>
+`
+
+var wasmPageTmpl = template.Must(template.New("wasm-page").Parse(wasmPage))
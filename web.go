@@ -17,17 +17,34 @@ package mcpi
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"image/color"
-	"log"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"mime"
 	"net"
 	"net/http"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"go-hep.org/x/hep/hbook"
 	"go-hep.org/x/hep/hplot"
 	"golang.org/x/net/websocket"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
 	"gonum.org/v1/plot/vg/draw"
@@ -35,21 +52,531 @@ import (
 )
 
 // Plot plots a point at (x,y)
+//
+// The point is inside if x²+y²<1, and outside otherwise: points on the unit
+// circle are outside, unless WithInclusiveBoundary is used.
+//
+// Plot is safe for concurrent use by multiple goroutines.
+// Points are handed over, one at a time, to a single goroutine that
+// accumulates them: Plot returns once its point has been accepted, and
+// concurrent callers are served in the order they started waiting, so no
+// producer can starve the others.
+// Every point whose Plot call returned before Quit is counted.
+//
+// Points plotted once the server is shutting down are discarded:
+// see PlotErr to detect it.
+//
+// The first call to Plot starts the web plot server, if needed.
 func Plot(x, y float64) {
-	srv.datac <- [2]float64{x, y}
+	_ = srv.send([4]float64{x, y, 1, -1})
+}
+
+// ErrClosed is returned when plotting points once the server is shutting
+// down or stopped.
+var ErrClosed = errors.New("mcpi: server closed")
+
+// PlotErr plots a point at (x,y), like Plot.
+// PlotErr returns ErrClosed, without blocking, if the server is shutting
+// down or stopped (e.g. by Quit or after the maximum runtime.)
+func PlotErr(x, y float64) error {
+	return srv.send([4]float64{x, y, 1, -1})
+}
+
+// PlotWeighted plots a point at (x,y) with the weight w, e.g. an importance
+// sampling weight.
+// Pi is then estimated as 4*sum(w_inside)/sum(w), which reduces to 4*inside/n
+// when all weights are 1, as with Plot.
+// Points with a non-positive, infinite or NaN weight are rejected (see
+// Rejected).
+//
+// See Plot for the concurrency semantics.
+func PlotWeighted(x, y, w float64) {
+	_ = srv.send([4]float64{x, y, w, -1})
+}
+
+// PlotCategory plots a point at (x,y), like Plot, but drawn in the color of
+// the category c (see WithCategoryColors) rather than as an inside or outside
+// point, e.g. to show the cells of a stratified sampling.
+// The point is still accounted for in the estimate of Pi.
+//
+// By default, the two categories are drawn in the colors of the inside and
+// outside points. Points of other categories are rejected (see Rejected).
+// All the points of the categories are drawn, regardless of the draw limits.
+// Categories are not drawn with WithClientRendering.
+func PlotCategory(x, y float64, c int) {
+	if c < 0 {
+		c = -2 // rejected: -1 stands for inside or outside points
+	}
+	_ = srv.send([4]float64{x, y, 1, float64(c)})
+}
+
+// Plots plots the (x,y) points of pts, like Plot.
+// Plots returns ErrClosed, without plotting the remaining points, if the
+// server is shutting down or stopped.
+//
+// Points handed over in a row, as with Plots, may be processed in batches,
+// see WithBatchSize.
+func Plots(pts [][2]float64) error {
+	for _, pt := range pts {
+		err := srv.send([4]float64{pt[0], pt[1], 1, -1})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlotMatrix plots the (x,y) points of the rows of m, like Plots.
+// PlotMatrix returns an error, without plotting any point, if m does not
+// have exactly two columns.
+func PlotMatrix(m mat.Matrix) error {
+	rows, cols := m.Dims()
+	if cols != 2 {
+		return fmt.Errorf("mcpi: invalid matrix shape (rows=%d, cols=%d, want cols=2)", rows, cols)
+	}
+	for i := 0; i < rows; i++ {
+		err := srv.send([4]float64{m.At(i, 0), m.At(i, 1), 1, -1})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlotXYer plots the (x,y) points of xy, like Plots, e.g. the data of
+// another gonum plotter. An empty xy plots nothing.
+// PlotXYer returns an error, without plotting any point, if xy has a
+// negative number of points.
+func PlotXYer(xy plotter.XYer) error {
+	n := xy.Len()
+	if n < 0 {
+		return fmt.Errorf("mcpi: invalid number of points (n=%d)", n)
+	}
+	for i := 0; i < n; i++ {
+		x, y := xy.XY(i)
+		err := srv.send([4]float64{x, y, 1, -1})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlotChan plots the (x,y) points received from ch, until ch is closed,
+// done is closed or the server is stopped (e.g. by Quit).
+// PlotChan blocks until then. A nil done channel is never closed.
+//
+// See Plot for the concurrency semantics.
+func PlotChan(ch <-chan [2]float64, done <-chan struct{}) {
+	_ = srv.autostart()
+	for {
+		select {
+		case pt, ok := <-ch:
+			if !ok {
+				return
+			}
+			srv.stats.pending.Add(1)
+			select {
+			case srv.datac <- [4]float64{pt[0], pt[1], 1, -1}:
+				srv.stats.pending.Add(-1)
+			case <-done:
+				srv.stats.pending.Add(-1)
+				return
+			case <-srv.closing:
+				srv.stats.pending.Add(-1)
+				return
+			}
+		case <-done:
+			return
+		case <-srv.closing:
+			return
+		}
+	}
+}
+
+// Flush blocks until all the points plotted before the call to Flush,
+// from any goroutine, have been processed, including the frames they trigger.
+// This is useful to measure the end-to-end throughput:
+//
+//	b.ResetTimer()
+//	for i := 0; i < b.N; i++ {
+//		mcpi.Plot(rand.Float64(), rand.Float64())
+//	}
+//	mcpi.Flush()
+//	b.StopTimer()
+//
+// Flush returns immediately if the server is stopped.
+func Flush() {
+	// points are handed over to the run loop one at a time, and processed
+	// before it executes any operation: an empty operation acts as a sentinel.
+	srv.do(func() {})
 }
 
 // Wait waits for the plot to be finished
+//
+// Wait starts the web plot server if needed, and waits for a web client
+// to load the plot page.
+// Wait returns immediately if the web plot server could not be started,
+// e.g. when its address is already in use; the error is logged.
 func Wait() {
-	<-srv.wait
-	srv.start = time.Now()
+	if srv.autostart() != nil {
+		return
+	}
+	select {
+	case <-srv.wait:
+	case <-srv.stopped:
+	}
+	srv.setStart(srv.now())
 }
 
 // Quit closes the web plot server.
+//
+// Quit returns immediately if the server has already stopped,
+// e.g. after the maximum runtime has been reached.
 func Quit() {
-	log.Printf("total runtime: %v", time.Since(srv.start))
-	srv.done <- 1
-	<-srv.quit
+	select {
+	case srv.done <- 1:
+	case <-srv.stopped:
+	}
+	<-srv.stopped
+}
+
+// Start starts the web plot server, listening on a free TCP port.
+// The address of the plot page is logged at slog.LevelInfo.
+// Start is a no-op if the server is already started.
+//
+// Importing the package does not start the web plot server: it is started
+// by Start, or by the first call to Plot or Wait.
+func Start() error {
+	return srv.listen()
+}
+
+// ServeUnix starts the web plot server, listening on the Unix domain socket
+// at path rather than on a TCP port, e.g. for sandboxed environments or
+// behind a local reverse proxy. A stale socket at path is removed, and the
+// socket is removed when the server is stopped, by Stop or Quit.
+// ServeUnix must be called before the web plot server is started, e.g. by
+// Plot or Wait.
+func ServeUnix(path string) error {
+	return srv.listenUnix(path)
+}
+
+// Stop stops the web plot server started by Start and disconnects its
+// clients. Plotted points are still accumulated: Start may be called again
+// to serve them on a new port.
+// Stop is a no-op if the server is not started.
+func Stop() error {
+	return srv.close()
+}
+
+// SetMaxRuntime stops the web plot server once d has elapsed since the
+// call to Wait, or since the package initialization if Wait is not called.
+// A final frame is emitted, pending and subsequent calls to Wait, Plot and
+// Quit return immediately.
+// A zero or negative duration disables the limit, which is the default.
+func SetMaxRuntime(d time.Duration) {
+	srv.update(func(cfg *config) {
+		cfg.maxRuntime = d
+	})
+}
+
+// SetTickInterval sets the interval at which the periodic tasks of the
+// web plot server, such as checking the maximum runtime, are performed.
+//
+// On each tick, a new frame is emitted if points were plotted since the last
+// one, so the display keeps refreshing between the decade frames (see
+// SetLogBase).
+// To bound the rendering overhead, a new frame is only emitted once at least
+// the rendering duration of the previous frame has elapsed.
+// A zero or negative interval restores the default, 100ms.
+func SetTickInterval(d time.Duration) {
+	if d <= 0 {
+		d = defaultTick
+	}
+	srv.do(func() {
+		srv.ticker.Reset(d)
+		srv.tick = d
+	})
+}
+
+// Handler returns the HTTP handler serving the plot page and its data
+// endpoints, so they can be mounted into an existing server:
+//
+//	mux.Handle("/pi/", http.StripPrefix("/pi", mcpi.Handler()))
+//
+// The page is then available at "/pi/", and Start does not need to be called.
+// The last rendered frame is also served as a PNG image, at "/pi/preview.png",
+// and the runtime configuration can be read and updated, as JSON, at "/pi/config".
+// The frames are also streamed as MJPEG, for viewers without websockets, at
+// "/pi/stream.mjpeg", displayed by a script-free page at "/pi/stream".
+// Other paths, e.g. "/pi/bogus", are answered with a 404 status.
+func Handler() http.Handler {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.mounted = true
+	return srv
+}
+
+// Estimate returns the current estimate of Pi, 4*inside/n.
+// For weighted points, Pi is estimated as 4*sum(w_inside)/sum(w).
+//
+// Only accepted points, inside or outside, are accounted for in n: points
+// rejected by WithStrictDomain do not bias the estimate.
+// Estimate returns NaN until a point has been accounted for.
+func Estimate() float64 {
+	var pi float64
+	srv.read(func() { pi = srv.estimate() })
+	return pi
+}
+
+// AbsError returns the absolute error of the current estimate of Pi,
+// |estimate-π|, or NaN until a point has been accounted for.
+func AbsError() float64 {
+	var err float64
+	srv.read(func() { err = math.Abs(srv.estimate() - math.Pi) })
+	return err
+}
+
+// CorrectDigits returns the number of correct decimal digits of the current
+// estimate of Pi, -log10(|estimate-π|/π), a compact measure of its accuracy.
+// The number is capped to 16, the precision of a float64, for an estimate
+// equal to π. CorrectDigits returns NaN until a point has been accounted for.
+func CorrectDigits() float64 {
+	var d float64
+	srv.read(func() { d = srv.digits() })
+	return d
+}
+
+// Fraction returns the fraction of the inside points, inside/n, or
+// sum(w_inside)/sum(w) for weighted points.
+// Fraction returns NaN until a point has been accounted for.
+func Fraction() float64 {
+	var f float64
+	srv.read(func() { f = srv.win / srv.wsum })
+	return f
+}
+
+// Area returns the estimate of the area of the inside region, the fraction
+// of the inside points times the area of the sampled square: π/4 for the
+// quarter disk of the [0,1]x[0,1] square, π for the disk of UseFullCircle.
+// Area returns NaN until a point has been accounted for.
+func Area() float64 {
+	var a float64
+	srv.read(func() { a = srv.area(srv.config()) })
+	return a
+}
+
+// Rejected returns the number of rejected points: points outside of the
+// domain with WithStrictDomain, points with an invalid weight or category,
+// and points dropped in excess of the intake rate with WithIntakeDrop.
+func Rejected() int {
+	var n int
+	srv.read(func() { n = srv.rejected })
+	return n
+}
+
+// ClientCount returns the number of currently connected web clients.
+func ClientCount() int {
+	return srv.hub.len()
+}
+
+// SetLogger sets the logger used by mcpi.
+//
+// Routine messages, such as the emission of a new frame, are logged at
+// slog.LevelDebug. The listening address and the run summary are logged
+// at slog.LevelInfo.
+// A nil logger restores the default, slog.Default().
+func SetLogger(l *slog.Logger) {
+	srv.update(func(cfg *config) {
+		cfg.log = l
+	})
+}
+
+// SetBasePath sets the URL path prefix under which the plot page and
+// its data endpoint are served, e.g. "/mcpi" when running behind a reverse proxy.
+// The page is then available at "/mcpi/" and its data at "/mcpi/data".
+// An empty prefix (the default) serves everything from the root.
+func SetBasePath(prefix string) {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix = "/" + prefix
+	}
+	srv.update(func(cfg *config) {
+		cfg.base = prefix
+	})
+}
+
+// SetDrawLimit sets the maximum number of inside and outside points
+// that are drawn on each frame to n.
+// A negative value draws all the points.
+//
+// The draw limit is purely a rendering cap: all points contribute to
+// the estimate of Pi.
+// When a limit is reached, inside and outside points are capped by the same
+// fraction, so the drawn inside/outside ratio matches the true one.
+// By default, at most 1e6 inside and 1e6 outside points are drawn.
+func SetDrawLimit(n int) {
+	SetDrawLimits(n, n)
+}
+
+// SetDrawLimits sets the maximum number of inside and outside points
+// that are drawn on each frame, independently.
+// A negative value draws all the points of that region.
+//
+// See SetDrawLimit for details.
+func SetDrawLimits(in, out int) {
+	srv.update(func(cfg *config) {
+		cfg.pmaxIn = in
+		cfg.pmaxOut = out
+	})
+}
+
+// OnMilestone registers fn to be called once each power of ten number of
+// points (10, 100, 1000, ...) is reached, with that number of points and
+// the current estimate of Pi, e.g. to print the estimate as it converges.
+//
+// When points are accounted for in batches (see AddCounts), fn is called
+// once for each crossed power of ten, with the estimate after the batch.
+// fn is called from the goroutine accumulating the points: it must not
+// block, nor call the functions of this package.
+// A nil fn unregisters the previous one.
+func OnMilestone(fn func(n int, pi float64)) {
+	srv.update(func(cfg *config) {
+		cfg.onMilestone = fn
+	})
+}
+
+// SetPlotFraction sets the fraction f of the plotted points that are drawn,
+// chosen randomly, e.g. to keep the visualization of high sample rates cheap.
+// All the points are still accounted for in the estimate of Pi.
+// By default, all the points are drawn.
+func SetPlotFraction(f float64) {
+	srv.update(func(cfg *config) {
+		cfg.frac = f
+	})
+}
+
+// SetLogBase sets the base b of the geometric schedule of the frames: below
+// b points, a frame is emitted for each point, then every b points below b²
+// points, and so on, up to every 1e7 points.
+// E.g. a base of 2 emits frames at 1, 2, 4, 8, 16, ... points.
+// The base must be larger than 1; the default is 10.
+func SetLogBase(b float64) {
+	if !(b > 1) || math.IsInf(b, 1) {
+		srv.logger().Error("invalid log base, ignored", "base", b)
+		return
+	}
+	srv.update(func(cfg *config) {
+		cfg.logBase = b
+	})
+}
+
+// Reserve grows the storage of the drawn points, so n more points can be
+// plotted without reallocating it, e.g. to avoid latency spikes in large runs.
+//
+// All the drawn points are retained, see SetPlotFraction: the storage is grown
+// for the expected number of drawn points, split between the inside and
+// outside points in the π/4 ratio of the disk, and bounded by the memory
+// budget, see SetMemoryBudget.
+// Reserve is a no-op once the server has stopped.
+func Reserve(n int) {
+	srv.do(func() {
+		cfg := srv.config()
+		if n <= 0 || cfg.frac <= 0 {
+			return
+		}
+		m := float64(n) * math.Min(cfg.frac, 1)
+		nin := int(math.Ceil(m * math.Pi / 4))
+		nout := int(math.Ceil(m * (1 - math.Pi/4)))
+		if k := srv.affordable(cfg, nin+nout); k < nin+nout {
+			// the points beyond the memory budget are binned.
+			nin = int(float64(k) * math.Pi / 4)
+			nout = k - nin
+		}
+		srv.in.grow(nin)
+		srv.out.grow(nout)
+		if srv.inW != nil {
+			srv.inW = slices.Grow(srv.inW, nin)
+			srv.outW = slices.Grow(srv.outW, nout)
+		}
+	})
+}
+
+// SetPixelSize sets the size of the rendered images to w×h pixels, at the
+// resolution of dpi dots per inch.
+// The images are rasterized at a whole number of dots per inch: dpi is
+// rounded, and the size computed for the rounded resolution, so the images
+// are exactly w×h pixels.
+// By default, images are 20cm wide and high at 96 dpi, i.e. 756×756 pixels.
+// Non-positive values restore the defaults.
+// Sizes exceeding the pixel budget (see SetMaxPixels) are logged and ignored.
+func SetPixelSize(w, h int, dpi float64) {
+	if w > 0 && h > 0 && float64(w)*float64(h) > float64(srv.config().maxPixels) {
+		srv.logger().Error(
+			"image size exceeds the pixel budget, use a smaller size",
+			"width", w, "height", h, "budget", srv.config().maxPixels,
+		)
+		return
+	}
+	d := int(math.Round(dpi))
+	srv.update(func(cfg *config) {
+		if w <= 0 || h <= 0 || d <= 0 {
+			cfg.size = [2]vg.Length{defaultSize, defaultSize}
+			cfg.dpi = vgimg.DefaultDPI
+			return
+		}
+		cfg.size = [2]vg.Length{
+			vg.Length(w) * vg.Inch / vg.Length(d),
+			vg.Length(h) * vg.Inch / vg.Length(d),
+		}
+		cfg.dpi = d
+	})
+}
+
+// UseFullCircle switches to the full-circle formulation of the Monte-Carlo
+// method: points are sampled in [-1,1]x[-1,1] instead of [0,1]x[0,1], and
+// the axes are adjusted accordingly.
+//
+// Points with x²+y²<1 are still counted as inside, and Pi is still estimated
+// as 4*inside/n, since the unit disk covers π/4 of the [-1,1]x[-1,1] square,
+// just like the quarter disk covers π/4 of the [0,1]x[0,1] square.
+func UseFullCircle() {
+	srv.update(func(cfg *config) {
+		cfg.domain = [2]float64{-1, 1}
+	})
+}
+
+// SetName tags the run with name, e.g. the random source or the seed of the
+// simulation, shown in the title of the plot and of the plot page, to tell
+// the runs apart when comparing them side by side.
+// An empty name, the default, removes the tag.
+func SetName(name string) {
+	srv.update(func(cfg *config) {
+		cfg.name = name
+	})
+}
+
+// SetClock sets the clock of the start time, of the elapsed time (see
+// SetMaxRuntime) and of the emission times of the frames, and restarts the
+// elapsed time.
+// SetClock is meant for tests, e.g. to record reproducible frames.
+// A nil clock restores the default one, time.Now.
+func SetClock(now func() time.Time) {
+	srv.update(func(cfg *config) {
+		cfg.clock = now
+	})
+	srv.setStart(srv.now())
+}
+
+// SetBasicAuth protects the plot page and its data endpoint with
+// HTTP Basic Authentication, using the provided credentials.
+// Requests without valid credentials are rejected with a 401 status.
+// An empty user disables authentication, which is the default.
+func SetBasicAuth(user, pass string) {
+	srv.update(func(cfg *config) {
+		cfg.user = user
+		cfg.pass = pass
+	})
 }
 
 func init() {
@@ -61,132 +588,933 @@ var (
 )
 
 type server struct {
-	in  plotter.XYs
-	out plotter.XYs
-	n   int
+	mu     sync.Mutex
+	cfg    config
+	mux    *http.ServeMux
+	web    *http.Server // web-server started by Start, if any
+	ln     net.Listener // listener of web
+	cancel func()       // cancels the requests of web
+
+	once    sync.Once // starts the web-server on first use
+	lerr    error     // error of the web-server started on first use, if any
+	mounted bool      // whether the handler is served by a user-provided server
+	signals sync.Once // installs the signal handler of HandleSignals
+
+	in       xys
+	out      xys
+	n        int
+	win      float64       // sum of the weights of the inside points
+	wsum     float64       // sum of the weights of all the points
+	inW      []float64     // weights of the inside points, if not all 1
+	outW     []float64     // weights of the outside points, if not all 1
+	cats     []plotter.XYs // points of each category, see PlotCategory
+	binned   *hbook.H2D    // points beyond the memory budget, see SetMemoryBudget
+	rejected int           // number of rejected points
+	burnt    int           // number of points discarded by the burn-in, see SetBurnIn
+	burned   xys           // drawn points discarded by the burn-in
+	baseline plotter.XYs   // points of the previous run, see Baseline
+	gen      int           // generation of the drawn state, bumped by the changes not counted in n
+	pending  bool          // whether a frame was skipped while no client was connected
+	paused   bool          // whether points are left waiting, see controlHandle
+	bucket   bucket        // limiter of the intake of points, see SetIntakeRate
+	ewma     struct {
+		pi float64 // moving average of the estimate, see WithSmoothing
+		ok bool    // whether pi holds a value
+	}
+	frame struct {
+		n    int           // number of points of the last rendered frame
+		end  time.Time     // end time of the last frame rendering
+		cost time.Duration // duration of the last frame rendering
+
+		beg      time.Time     // start time of the last frame rendering
+		interval time.Duration // moving average of the interval between frames, see WithAdaptiveDPI
+		dpi      int           // resolution of the last rendered image
+	}
+	cache  pngCache
+	pngs   frameStore // images of the frames, see WithFrameURLs
+	trail  *ring      // estimates of the last frames
+	strail *ring      // smoothed estimates of the last frames
+	ltrail *ring      // Leibniz estimates of the last frames
+	lsum   series     // Leibniz series, see EnableLeibnizComparison
+	series []point    // convergence series, see converge
+	tee    *tee       // frames tee, see Tee
+	stats  stats      // counters of Diagnostics
+	counts counters   // observers of Counts
+	term   *terminal  // terminal plot, see ServeTUI
+
+	datac   chan [4]float64 // (x,y,w,c) points, c the category or -1
+	ops     chan func()     // operations executed by the run loop
+	hub     *hub
+	wait    chan int
+	done    chan int
+	closing chan struct{} // closed when the run loop starts finishing
+	stopped chan struct{} // closed when the run loop has finished
+	ticker  *time.Ticker  // drives the periodic tasks of the run loop
+	tick    time.Duration // interval of ticker
+	start   time.Time
+}
+
+type config struct {
+	version int // incremented on each configuration change
+
+	log     *slog.Logger
+	base    string   // URL path prefix, without trailing slash
+	origins []string // allowed cross-origin requests
+	user    string   // basic-auth credentials
+	pass    string
+
+	rec  *json.Encoder // frames recorder
+	lazy bool          // whether to render frames only when a client is connected
+
+	pmaxIn  int // maximum number of drawn inside points
+	pmaxOut int // maximum number of drawn outside points
+
+	assets string // directory of the WebAssembly client assets
+
+	domain [2]float64 // [min,max] range of the sampled square, along x and y
+
+	maxRuntime time.Duration // duration after which the server stops
+
+	fade bool // whether to fade out older points
+
+	pixelRate int // budget of rendered pixels per second, see WithAdaptiveDPI
+
+	burnIn     int  // number of discarded first points, see SetBurnIn
+	showBurnIn bool // whether to draw the discarded points
+
+	strict  bool // whether to reject points outside of the domain
+	wradius bool // whether to size points by their weight
+
+	radius vg.Length      // radius of the drawn points
+	colors [2]color.Color // colors of the inside and outside points
+	prec   int            // number of digits of the displayed estimate, -1 for the shortest
+
+	trails int        // number of tracked estimates drawn in the sparkline
+	face   *font.Face // font of the plot text, nil for the default one
+	ascii  bool       // whether to write "pi" instead of "π"
+	quick  bool       // whether to skip the final frame when nobody watches
+	abserr bool       // whether to annotate the plot with the error of the estimate
+	area   float64    // reference area of the inside region, see SetReferenceArea
+
+	autoscale bool         // whether the axes fit the extent of the points
+	size      [2]vg.Length // width and height of the rendered images
+	dpi       int          // resolution of the rendered images
+	nohint    bool         // whether to suppress the hint about calling Wait
+	frac      float64      // fraction of the points that are drawn
+	rate      float64      // maximum number of accepted points per second
+	drop      bool         // whether to drop the points in excess of rate
+	alpha     float64      // smoothing factor of the moving average of the estimate
+
+	onMilestone func(n int, pi float64) // called at each power of ten points
+	ticks       plot.Ticker             // ticks of the axes, nil for the default ones
+	batch       int                     // maximum number of points processed per batch
+	inclusive   bool                    // whether the points on the circle are inside
+	eps         float64                 // tolerance of the inclusive inside test
+	cats        []color.Color           // colors of the categories, nil for the inside and outside colors
+	tol         float64                 // tolerance of the convergence marked on the sparkline
+	maxPixels   int                     // maximum number of pixels of the rendered images
+	heatmap     bool                    // whether to draw the density of the points
+	legend      bool                    // whether to draw the legend of the scatters
+	labels      [2]string               // legend labels of the inside and outside points
+	leibniz     bool                    // whether to draw the Leibniz series on the sparkline
+	transparent bool                    // whether to render on a transparent background
+	budget      int64                   // maximum size of the storage of the drawn points, in bytes
+	climit      int                     // maximum number of estimates of the convergence series
+	name        string                  // name of the run, see SetName
+	rng         *rand.Rand              // seeded source of the random choices, nil for the global one
+	digits      bool                    // whether to show the number of correct digits in the title
+	urls        bool                    // whether to serve the images of the frames at their own URL
+	hexbin      float64                 // radius of the hexagonal cells of the density, 0 to disable
+	accuracy    *threshold              // crossing of the tolerance of OnAccuracy, if any
+	hold        int                     // number of points the estimate must stay within the tolerance
+	clock       func() time.Time        // clock of the elapsed time, nil for time.Now
+	refs        bool                    // whether to draw reference markers
+	ydown       bool                    // whether the y axis grows downward, see WithYAxisDown
+	math        bool                    // whether to draw the title and labels as LaTeX, see WithMathText
+	clamp       bool                    // whether to clamp the drawn points to the axes
+	heatSample  int                     // size of the subsample of the heatmap, see WithHeatmapSample
+	heatSeed    int64                   // seed of the subsample of the heatmap
+	shuffle     bool                    // whether to draw a random subset of the points, see WithShuffledDraw
+	seed        int64                   // seed of the drawn subset
+	ping        time.Duration           // interval of the pings of the web clients
+	logBase     float64                 // base of the geometric schedule of the frames
+	timeout     time.Duration           // delay after which silent or stuck web clients are disconnected
+}
+
+func newConfig() config {
+	return config{
+		pmaxIn:    1e6,
+		pmaxOut:   1e6,
+		domain:    [2]float64{0, 1},
+		size:      [2]vg.Length{defaultSize, defaultSize},
+		dpi:       vgimg.DefaultDPI,
+		frac:      1,
+		batch:     1,
+		maxPixels: defaultMaxPixels,
+		radius:    0.5,
+		colors: [2]color.Color{
+			color.RGBA{255, 0, 0, 255},
+			color.RGBA{0, 0, 255, 255},
+		},
+		prec:    -1,
+		logBase: 10,
+		hold:    1,
+		ping:    defaultPing,
+		timeout: defaultTimeout,
+	}
+}
+
+// format formats the estimate of Pi with the configured precision.
+func (cfg config) format(pi float64) string {
+	if cfg.prec < 0 {
+		return strconv.FormatFloat(pi, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(pi, 'f', cfg.prec, 64)
+}
+
+func (srv *server) config() config {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.cfg
+}
+
+// update applies f to the configuration of the server.
+func (srv *server) update(f func(cfg *config)) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	f(&srv.cfg)
+	srv.cfg.version++
+}
+
+func (srv *server) logger() *slog.Logger {
+	if l := srv.config().log; l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+func (srv *server) setStart(t time.Time) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.start = t
+}
+
+func (srv *server) elapsed() time.Duration {
+	now := srv.now()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return now.Sub(srv.start)
+}
 
-	datac chan [2]float64
-	plots chan wplot
-	quit  chan int
-	wait  chan int
-	done  chan int
-	start time.Time
+// now returns the current time of the clock set with SetClock.
+func (srv *server) now() time.Time {
+	if now := srv.config().clock; now != nil {
+		return now()
+	}
+	return time.Now()
 }
 
+// defaultTick is the default interval of the run loop ticker.
+const defaultTick = 100 * time.Millisecond
+
 func newServer() *server {
 	srv := &server{
-		in:    make(plotter.XYs, 0, 1024),
-		out:   make(plotter.XYs, 0, 1024),
-		datac: make(chan [2]float64),
-		plots: make(chan wplot),
-		quit:  make(chan int),
-		wait:  make(chan int),
-		done:  make(chan int),
+		cfg:     newConfig(),
+		in:      xys{f64: make(plotter.XYs, 0, 1024)},
+		out:     xys{f64: make(plotter.XYs, 0, 1024)},
+		datac:   make(chan [4]float64),
+		ops:     make(chan func()),
+		hub:     newHub(),
+		wait:    make(chan int),
+		done:    make(chan int),
+		closing: make(chan struct{}),
+		stopped: make(chan struct{}),
+		ticker:  time.NewTicker(defaultTick),
+		tick:    defaultTick,
+		start:   time.Now(),
+		mux:     http.NewServeMux(),
 	}
+	srv.pngs.run = strconv.FormatInt(srv.start.UnixNano(), 36)
+	srv.mux.HandleFunc("/", srv.plotHandle)
+	srv.mux.HandleFunc("/favicon.ico", faviconHandle)
+	srv.mux.HandleFunc("/frame/", srv.frameHandle)
+	srv.mux.HandleFunc("/wasm/", srv.assetsHandle)
+	srv.mux.HandleFunc("/preview.png", srv.previewHandle)
+	srv.mux.HandleFunc("/config", srv.configHandle)
+	srv.mux.HandleFunc("/render", srv.renderHandle)
+	srv.mux.HandleFunc("/merge", srv.mergeHandle)
+	srv.mux.HandleFunc("/control", srv.controlHandle)
+	srv.mux.HandleFunc("/convergence.json", srv.convergenceHandle)
+	srv.mux.HandleFunc("/stream", srv.streamPageHandle)
+	srv.mux.HandleFunc("/stream.mjpeg", srv.streamHandle)
+	srv.mux.Handle("/data", srv.keepAlive(websocket.Server{
+		Handler:   srv.dataHandler,
+		Handshake: srv.checkOrigin,
+	}))
 
-	go srv.serve()
 	go srv.run()
 
 	return srv
 }
 
 func (srv *server) run() {
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+	defer srv.ticker.Stop()
 
 	for {
-		select {
-		case v := <-srv.datac:
-			srv.n++
-			x := v[0]
-			y := v[1]
-			d2 := x*x + y*y
-			pt := struct{ X, Y float64 }{x, y}
-			switch {
-			case d2 < 1:
-				srv.in = append(srv.in, pt)
-			default:
-				srv.out = append(srv.out, pt)
+		var (
+			cfg   = srv.config()
+			rate  = cfg.rate
+			datac = srv.datac
+			wake  <-chan time.Time
+		)
+		if rate > 0 {
+			srv.bucket.refill(rate, time.Now())
+		}
+		switch {
+		case srv.paused:
+			datac = nil // block senders until resumed
+		case rate > 0 && !cfg.drop:
+			if d := srv.bucket.delay(rate); d > 0 {
+				datac = nil // block senders until a token is available
+				wake = time.After(d)
 			}
-			switch {
-			case srv.n < 1e1:
-				if srv.n%1e0 == 0 {
-					srv.plots <- plot(srv.n, srv.in, srv.out)
-				}
-			case srv.n < 1e2:
-				if srv.n%1e1 == 0 {
-					srv.plots <- plot(srv.n, srv.in, srv.out)
-				}
-			case srv.n < 1e3:
-				if srv.n%1e2 == 0 {
-					srv.plots <- plot(srv.n, srv.in, srv.out)
-				}
-			case srv.n < 1e4:
-				if srv.n%1e3 == 0 {
-					srv.plots <- plot(srv.n, srv.in, srv.out)
-				}
-			case srv.n < 1e5:
-				if srv.n%1e4 == 0 {
-					srv.plots <- plot(srv.n, srv.in, srv.out)
-				}
-			case srv.n < 1e6:
-				if srv.n%1e5 == 0 {
-					srv.plots <- plot(srv.n, srv.in, srv.out)
-				}
-			case srv.n < 1e7:
-				if srv.n%1e6 == 0 {
-					srv.plots <- plot(srv.n, srv.in, srv.out)
+		}
+		select {
+		case <-wake:
+		case v := <-datac:
+			// the configuration may have changed while waiting: take a
+			// snapshot for the whole batch.
+			cfg = srv.config()
+			prev := srv.n
+			srv.intake(cfg, v, rate)
+			// process the points already waiting, up to the batch size,
+			// and evaluate the frame cadence once for the whole batch.
+		batch:
+			for i := 1; i < cfg.batch; i++ {
+				if rate > 0 && !cfg.drop && srv.bucket.delay(rate) > 0 {
+					break
 				}
-			case srv.n > 1e7:
-				if srv.n%1e7 == 0 {
-					srv.plots <- plot(srv.n, srv.in, srv.out)
+				select {
+				case v := <-srv.datac:
+					srv.intake(cfg, v, rate)
+				default:
+					break batch
 				}
 			}
+			if srv.n == prev {
+				continue
+			}
+			srv.counts.publish(srv.counted())
+			if prev == 0 {
+				srv.hint(cfg)
+			}
+			srv.advance(cfg, prev)
+		case op := <-srv.ops:
+			op()
+		case <-srv.hub.connected:
+			if srv.pending {
+				srv.render()
+			}
+		case <-srv.ticker.C:
+			// a skipped frame is rendered once a client connects, not
+			// skipped again on each tick.
+			if srv.n != srv.frame.n && !srv.pending && time.Since(srv.frame.end) >= srv.frame.cost {
+				srv.emit()
+			}
+			if d := srv.config().maxRuntime; d > 0 && srv.elapsed() > d {
+				srv.logger().Info("maximum runtime reached", "max", d)
+				srv.finish()
+				return
+			}
 		case <-srv.done:
-			log.Printf("final: n=%d", srv.n)
-			srv.plots <- plot(srv.n, srv.in, srv.out)
-			time.Sleep(1 * time.Second) // give the server some time to update
-			srv.quit <- 1
+			srv.finish()
 			return
 		}
 	}
 }
 
-func plot(n int, in, out plotter.XYs) wplot {
-	const (
-		pmax   = 1e6
-		radius = vg.Length(0.5)
+// milestone returns the spacing of the frames emitted around n points, the
+// largest power of base up to n: with base 10, a frame for each point below
+// 10 points, every 10 points below 100 points, and so on, up to every 1e7
+// points.
+func milestone(n int, base float64) int {
+	if n < 1 {
+		return 1
+	}
+	k := math.Floor(math.Log(float64(n)) / math.Log(base))
+	m := math.Pow(base, k)
+	// correct the rounding errors of the logarithms, e.g. for n=1000.
+	switch {
+	case m*base <= float64(n):
+		m *= base
+	case m > float64(n):
+		m /= base
+	}
+	return int(math.Max(1, math.Min(m, 1e7)))
+}
+
+// hint logs a hint when the first point is plotted before any web client
+// connected, as the first frames would never reach the browser.
+func (srv *server) hint(cfg config) {
+	if cfg.nohint || cfg.lazy || srv.hub.len() > 0 {
+		return
+	}
+	srv.logger().Info(
+		"point plotted before any web client connected",
+		"hint", "call Wait before plotting, or use WithRenderWhenObserved",
 	)
+}
+
+// advance emits a new frame if the number of points crossed a milestone
+// since prev points.
+func (srv *server) advance(cfg config, prev int) {
+	if m := milestone(srv.n, cfg.logBase); prev/m != srv.n/m {
+		srv.emit()
+	}
+	if fn := cfg.onMilestone; fn != nil {
+		for m := 10; m <= srv.n; m *= 10 {
+			if prev < m {
+				fn(m, srv.estimate())
+			}
+			if m > math.MaxInt/10 {
+				break // the next power of ten overflows.
+			}
+		}
+	}
+}
+
+// do executes f on the run loop, and waits for its completion.
+// do returns false if the run loop has finished, without executing f.
+func (srv *server) do(f func()) bool {
+	done := make(chan struct{})
+	select {
+	case srv.ops <- func() { defer close(done); f() }:
+		<-done
+		return true
+	case <-srv.stopped:
+		return false
+	}
+}
+
+// read executes f on the run loop, or directly once the run loop has
+// finished and its state can not change anymore.
+func (srv *server) read(f func()) {
+	if !srv.do(f) {
+		f()
+	}
+}
+
+// send sends the (x,y,w,c) point to the run loop.
+func (srv *server) send(v [4]float64) error {
+	_ = srv.autostart()
+	srv.stats.pending.Add(1)
+	defer srv.stats.pending.Add(-1)
+	select {
+	case srv.datac <- v:
+		return nil
+	case <-srv.closing:
+		return ErrClosed
+	}
+}
+
+// finish emits the final frame, closes the web-server and stops the run loop.
+func (srv *server) finish() {
+	close(srv.closing)
+	defer func() {
+		// closing the listener also removes the socket of ServeUnix.
+		err := srv.close()
+		if err != nil {
+			srv.logger().Error("error closing web-server", "err", err)
+		}
+		close(srv.stopped)
+	}()
+	srv.logger().Info("total runtime", "elapsed", srv.elapsed())
+	srv.logger().Info("final", "n", srv.n)
+	srv.converge()
+	if cfg := srv.config(); cfg.quick && cfg.rec == nil && srv.tee == nil && srv.term == nil && srv.hub.len() == 0 {
+		return
+	}
+	srv.render()
+	if srv.tee != nil {
+		srv.tee.close()
+		srv.tee = nil
+	}
+	if srv.term != nil {
+		srv.term.close()
+		srv.term = nil
+	}
+	time.Sleep(1 * time.Second) // give the server some time to update
+}
+
+// emit emits a new frame, unless rendering is deferred until a client connects.
+func (srv *server) emit() {
+	srv.converge()
+	if srv.config().lazy && srv.hub.len() == 0 {
+		srv.stats.skipped.Add(1)
+		srv.pending = true
+		return
+	}
+	srv.render()
+}
+
+// render renders the current state and sends it to the connected web clients.
+func (srv *server) render() {
+	beg := time.Now()
+	defer func() {
+		srv.frame.n = srv.n
+		srv.frame.end = time.Now()
+		srv.frame.cost = srv.frame.end.Sub(beg)
+	}()
+
+	srv.pace(beg)
+	srv.pending = false
+	srv.logger().Debug("frame", "n", srv.n)
+	cfg := srv.config()
+	srv.track(cfg)
+	frame := wplot{
+		N:      srv.n,
+		Pi:     srv.estimate(),
+		Domain: srv.axes(cfg),
+	}
+	switch {
+	case cfg.assets != "":
+		frame.In, frame.Out = points(cfg, srv.in, srv.out)
+	default:
+		png, err := srv.png(cfg)
+		if err != nil {
+			// skip the frame: the next ones may render, e.g. once the
+			// offending points have left the plot.
+			srv.stats.failed.Add(1)
+			srv.logger().Error("could not render frame", "n", srv.n, "err", err)
+			return
+		}
+		frame.png = png
+		if cfg.urls {
+			frame.Seq = srv.pngs.put(frame.png)
+			break
+		}
+		frame.Plot = base64.StdEncoding.EncodeToString(frame.png)
+	}
+	select {
+	case <-srv.closing:
+		frame.Done = true // rendered by finish
+	default:
+	}
+	srv.stats.rendered.Add(1)
+	frame.Time = srv.now()
+	if srv.term != nil {
+		srv.term.send(srv.braille(cfg, frame))
+	}
+	srv.record(frame)
+	srv.hub.broadcast(frame)
+}
 
+// add accounts for the point (x,y) with weight w, drawn in the category c,
+// or as an inside or outside point if c is negative.
+// add returns false if the point was rejected.
+func (srv *server) add(cfg config, x, y, w float64, c int) bool {
+	if !(w > 0) || math.IsInf(w, 1) || !cfg.accept(x, y) || c < -1 || c >= len(cfg.categories()) {
+		srv.rejected++
+		return false
+	}
+	if srv.burn(cfg, x, y) {
+		return true // discarded by the burn-in
+	}
+	if w != 1 && srv.inW == nil {
+		// first non-unit weight: keep track of the weights of all points.
+		srv.inW = ones(srv.in.Len(), srv.in.cap())
+		srv.outW = ones(srv.out.Len(), srv.out.cap())
+	}
+
+	srv.n++
+	srv.wsum += w
+	inside := cfg.inside(x, y)
+	if inside {
+		srv.win += w
+	}
+	srv.smooth(cfg)
+	srv.watch(cfg)
+	if cfg.frac < 1 && !(cfg.rand() < cfg.frac) {
+		return true // accounted for, but not drawn
+	}
+	if srv.bin(cfg, x, y) {
+		return true // accounted for, and drawn on the heatmap only
+	}
+
+	pt := struct{ X, Y float64 }{x, y}
+	switch {
+	case c >= 0:
+		for len(srv.cats) <= c {
+			srv.cats = append(srv.cats, nil)
+		}
+		srv.cats[c] = append(srv.cats[c], pt)
+	case inside:
+		srv.in.push(x, y)
+		if srv.inW != nil {
+			srv.inW = append(srv.inW, w)
+		}
+	default:
+		srv.out.push(x, y)
+		if srv.outW != nil {
+			srv.outW = append(srv.outW, w)
+		}
+	}
+	return true
+}
+
+func ones(n, c int) []float64 {
+	vs := make([]float64, n, c)
+	for i := range vs {
+		vs[i] = 1
+	}
+	return vs
+}
+
+// inside returns whether the point (x,y) is inside the unit disk.
+func (cfg config) inside(x, y float64) bool {
+	d2 := x*x + y*y
+	if !cfg.inclusive {
+		return d2 < 1
+	}
+	return d2 <= 1+cfg.eps
+}
+
+// accept returns whether the point (x,y) should be accounted for.
+func (cfg config) accept(x, y float64) bool {
+	if !cfg.strict {
+		return true
+	}
+	lo, hi := cfg.domain[0], cfg.domain[1]
+	// NaNs fail all comparisons.
+	return lo <= x && x <= hi && lo <= y && y <= hi
+}
+
+// estimate returns the estimate of Pi from the weights of the inside points
+// and of all the points.
+// estimate is NaN when no point has been accounted for.
+func (srv *server) estimate() float64 {
+	return 4 * srv.win / srv.wsum
+}
+
+// maxDigits is the number of correct decimal digits of an estimate equal to
+// π, about the precision of a float64.
+const maxDigits = 16
+
+// digits returns the number of correct decimal digits of the estimate,
+// -log10(|estimate-π|/π), capped to [0,maxDigits].
+// digits is NaN when no point has been accounted for.
+func (srv *server) digits() float64 {
+	pi := srv.estimate()
+	if math.IsNaN(pi) {
+		return pi
+	}
+	rel := math.Abs(pi-math.Pi) / math.Pi
+	return math.Max(0, math.Min(maxDigits, -math.Log10(rel)))
+}
+
+// axes returns the [min,max] range of both axes of the plot: the sampled
+// domain, or the extent of the points with WithAutoscale.
+// Both axes share the same range, so that the disk stays round.
+func (srv *server) axes(cfg config) [2]float64 {
+	if !cfg.autoscale || srv.n == 0 {
+		return cfg.domain
+	}
+	lo, hi := math.Inf(+1), math.Inf(-1)
+	for _, pts := range srv.drawnPoints() {
+		for i := 0; i < pts.Len(); i++ {
+			x, y := pts.XY(i)
+			lo = math.Min(lo, math.Min(x, y))
+			hi = math.Max(hi, math.Max(x, y))
+		}
+	}
+	if !(lo < hi) {
+		return cfg.domain
+	}
+	return [2]float64{lo, hi}
+}
+
+// accuracy describes the absolute error of the estimate, and its number of
+// correct decimal digits, as shown by WithCorrectDigits.
+func (srv *server) accuracy() string {
+	err := math.Abs(srv.estimate() - math.Pi)
+	if err == 0 {
+		return "|error| = 0"
+	}
+	return fmt.Sprintf("|error| = %.2e, correct digits: %d", err, int(srv.digits()))
+}
+
+// plot creates the plot of the current state.
+func (srv *server) plot(cfg config) (*hplot.Plot, error) {
 	p := hplot.New()
 
 	p.X.Label.Text = "x"
-	p.X.Min = 0
-	p.X.Max = 1
+	axes := srv.axes(cfg)
+	p.X.Min = axes[0]
+	p.X.Max = axes[1]
 	p.Y.Label.Text = "y"
-	p.Y.Min = 0
-	p.Y.Max = 1
+	p.Y.Min = axes[0]
+	p.Y.Max = axes[1]
+	if cfg.ydown {
+		p.Y.Scale = plot.InvertedScale{Normalizer: p.Y.Scale}
+	}
+	if cfg.ticks != nil {
+		p.X.Tick.Marker = cfg.ticks
+		p.Y.Tick.Marker = cfg.ticks
+	}
 
-	pi := 4 * float64(len(in)) / float64(n)
-	p.Title.Text = fmt.Sprintf("n = %d\nπ = %v", n, pi)
+	err := cfg.style(p)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.transparent {
+		p.BackgroundColor = color.Transparent
+	}
+	pi := "n/a"
+	if srv.n > 0 {
+		pi = cfg.format(srv.estimate())
+	}
+	var digits string
+	if cfg.digits && srv.n > 0 {
+		digits = fmt.Sprintf(" (~%d digits)", int(srv.digits()))
+	}
+	ref := srv.reference(cfg)
+	p.Title.Text = fmt.Sprintf("n = %d\n%s = %s%s%s", srv.n, cfg.pi(), pi, digits, ref)
+	if cfg.name != "" {
+		p.Title.Text = cfg.name + "\n" + p.Title.Text
+	}
+	if cfg.math {
+		cfg.latex(p, fmt.Sprintf("$n = %d$\n$\\pi = %s$%s%s", srv.n, pi, digits, ref))
+	}
 
-	sin, err := hplot.NewScatter(in[:min(pmax, len(in))])
+	switch {
+	case cfg.hexbin > 0:
+		p.Add(srv.hexbins(cfg, axes, cfg.hexbin)...)
+		p.Add(hplot.NewGrid())
+	case cfg.heatmap || srv.binned != nil:
+		p.Add(srv.heatmap(cfg, axes)...)
+		p.Add(hplot.NewGrid())
+	default:
+		err := srv.scatters(p, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.refs {
+		err := cfg.references(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.abserr && srv.n > 0 {
+		p.Add(hplot.NewLabel(
+			0.02, 0.02, srv.accuracy(),
+			hplot.WithLabelNormalized(true),
+			hplot.WithLabelTextStyle(draw.TextStyle{
+				Color:   color.Black,
+				Font:    p.Y.Tick.Label.Font,
+				Handler: p.TextHandler,
+			}),
+		))
+	}
+	if cfg.trails > 0 && srv.trail != nil && len(srv.trail.vs) > 1 {
+		spark, err := srv.sparkline(cfg)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(inset{p: spark, frac: 0.3})
+	}
+
+	return p, nil
+}
+
+// references adds to p markers at the corners of the domain and at the
+// midpoint of the arc of the circle, labeled with their coordinates.
+func (cfg config) references(p *hplot.Plot) error {
+	lo, hi := cfg.domain[0], cfg.domain[1]
+	top := hi
+	if cfg.ydown {
+		top = lo
+	}
+	pts := plotter.XYs{
+		{X: lo, Y: lo}, {X: hi, Y: lo}, {X: lo, Y: hi}, {X: hi, Y: hi},
+		{X: math.Sqrt2 / 2, Y: math.Sqrt2 / 2},
+	}
+	s, err := hplot.NewScatter(pts)
+	if err != nil {
+		return fmt.Errorf("mcpi: could not create reference markers: %w", err)
+	}
+	s.GlyphStyle = draw.GlyphStyle{
+		Color:  color.Black,
+		Radius: vg.Points(4),
+		Shape:  draw.CrossGlyph{},
+	}
+	p.Add(s)
+	for _, pt := range pts {
+		p.Add(hplot.NewLabel(
+			pt.X, pt.Y, fmt.Sprintf(" (%.3g, %.3g) ", pt.X, pt.Y),
+			hplot.WithLabelTextStyle(draw.TextStyle{
+				Color:   color.Black,
+				Font:    p.Y.Tick.Label.Font,
+				Handler: p.TextHandler,
+				XAlign:  xalign(pt.X, hi),
+				YAlign:  yalign(pt.Y, top),
+			}),
+		))
+	}
+	return nil
+}
+
+// xalign returns the alignment of the label of a marker at x, so the labels
+// at the right edge hi of the domain stay within the plot.
+func xalign(x, hi float64) draw.XAlignment {
+	if x == hi {
+		return draw.XRight
+	}
+	return draw.XLeft
+}
+
+// yalign returns the alignment of the label of a marker at y, so the labels
+// at the edge of the domain drawn at the top of the plot, top, stay within
+// the plot.
+func yalign(y, top float64) draw.YAlignment {
+	if y == top {
+		return draw.YTop
+	}
+	return draw.YBottom
+}
+
+// thumbnail draws the legend glyph of points of the color c, larger than
+// the points themselves so it can be told apart.
+type thumbnail struct{ color.Color }
+
+func (c thumbnail) Thumbnail(cnv *draw.Canvas) {
+	cnv.DrawGlyph(draw.GlyphStyle{
+		Color:  c.Color,
+		Radius: vg.Points(3),
+		Shape:  draw.CircleGlyph{},
+	}, cnv.Center())
+}
+
+// scatters adds to p the scatters of the inside, outside and categories
+// points.
+func (srv *server) scatters(p *hplot.Plot, cfg config) error {
+	axes := srv.axes(cfg)
+	nin, nout := drawLens(cfg.pmaxIn, cfg.pmaxOut, srv.in.Len(), srv.out.Len())
+	din, iin := cfg.drawn(srv.in, nin)
+	dout, iout := cfg.drawn(srv.out, nout)
+	sin, err := hplot.NewScatter(cfg.clamped(din, axes))
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("mcpi: could not create scatter of inside points: %w", err)
 	}
-	sin.Color = color.RGBA{255, 0, 0, 255}
-	sin.Radius = radius
+	sin.Color = cfg.colors[0]
+	sin.Radius = cfg.radius
 
-	sout, err := hplot.NewScatter(out[:min(pmax/2, len(out))])
+	sout, err := hplot.NewScatter(cfg.clamped(dout, axes))
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("mcpi: could not create scatter of outside points: %w", err)
+	}
+	sout.Color = cfg.colors[1]
+	sout.Radius = cfg.radius
+
+	if cfg.fade {
+		sin.GlyphStyleFunc = fade(sin.GlyphStyle, nin)
+		sout.GlyphStyleFunc = fade(sout.GlyphStyle, nout)
+	}
+	if cfg.wradius && srv.inW != nil {
+		mean := srv.wsum / float64(srv.n)
+		sin.GlyphStyleFunc = weighted(sin.GlyphStyle, sin.GlyphStyleFunc, pick(srv.inW, iin), mean)
+		sout.GlyphStyleFunc = weighted(sout.GlyphStyle, sout.GlyphStyleFunc, pick(srv.outW, iout), mean)
 	}
-	sout.Color = color.RGBA{0, 0, 255, 255}
-	sout.Radius = radius
 
+	err = srv.baselineScatter(p, cfg, axes)
+	if err != nil {
+		return err
+	}
+	err = srv.burnScatter(p, cfg, axes)
+	if err != nil {
+		return err
+	}
 	p.Add(sin, sout, hplot.NewGrid())
+	if cfg.legend {
+		in, out := cfg.legends()
+		p.Legend.Add(in, thumbnail{cfg.colors[0]})
+		p.Legend.Add(out, thumbnail{cfg.colors[1]})
+		p.Legend.Top = true
+		p.Legend.Left = true
+	}
+	for c, pts := range srv.cats {
+		if len(pts) == 0 {
+			continue
+		}
+		sc, err := hplot.NewScatter(cfg.clamped(pts, axes))
+		if err != nil {
+			return fmt.Errorf("mcpi: could not create scatter of category %d: %w", c, err)
+		}
+		colors := cfg.categories()
+		sc.Color = colors[c%len(colors)]
+		sc.Radius = cfg.radius
+		p.Add(sc)
+	}
+	return nil
+}
+
+// fade returns a glyph style function for n points in insertion order,
+// where older points are drawn smaller and more transparent than recent ones.
+// The style decreases logarithmically with the age of a point.
+func fade(sty draw.GlyphStyle, n int) func(i int) draw.GlyphStyle {
+	c := color.NRGBAModel.Convert(sty.Color).(color.NRGBA)
+	return func(i int) draw.GlyphStyle {
+		f := 1.0
+		if n > 1 {
+			age := float64(n - 1 - i)
+			f = 1 - math.Log1p(age)/math.Log(float64(n))
+		}
+		sty := sty
+		sty.Radius = vg.Length(0.5+f) * sty.Radius
+		sty.Color = color.NRGBA{c.R, c.G, c.B, uint8(64 + f*191)}
+		return sty
+	}
+}
 
-	return wplot{Plot: renderImg(p)}
+// weighted returns a glyph style function scaling the radius of the points,
+// so the area of their glyph is proportional to their weight ws, relative
+// to the mean weight.
+// The base style is given by f if not nil, or sty otherwise.
+func weighted(sty draw.GlyphStyle, f func(int) draw.GlyphStyle, ws []float64, mean float64) func(i int) draw.GlyphStyle {
+	return func(i int) draw.GlyphStyle {
+		sty := sty
+		if f != nil {
+			sty = f(i)
+		}
+		sty.Radius *= vg.Length(math.Sqrt(ws[i] / mean))
+		return sty
+	}
+}
+
+// drawLens returns the number of inside and outside points to draw out of
+// nin and nout, given the draw limits pmaxIn and pmaxOut.
+// Both are scaled by the same fraction to preserve the inside/outside ratio.
+func drawLens(pmaxIn, pmaxOut, nin, nout int) (int, int) {
+	f := 1.0
+	if pmaxIn >= 0 && nin > pmaxIn {
+		f = math.Min(f, float64(pmaxIn)/float64(nin))
+	}
+	if pmaxOut >= 0 && nout > pmaxOut {
+		f = math.Min(f, float64(pmaxOut)/float64(nout))
+	}
+	if f == 1 {
+		return nin, nout
+	}
+	din := int(math.Round(f * float64(nin)))
+	dout := int(math.Round(f * float64(nout)))
+	if pmaxIn >= 0 {
+		din = min(din, pmaxIn)
+	}
+	if pmaxOut >= 0 {
+		dout = min(dout, pmaxOut)
+	}
+	return din, dout
 }
 
 func min(a, b int) int {
@@ -196,51 +1524,374 @@ func min(a, b int) int {
 	return b
 }
 
-func renderImg(p *hplot.Plot) string {
-	size := 20 * vg.Centimeter
-	canvas := vgimg.PngCanvas{vgimg.New(size, size)}
-	p.Draw(draw.New(canvas))
+// defaultSize is the default width and height of the rendered images.
+const defaultSize = 20 * vg.Centimeter
+
+// renderImg renders the plot as a square PNG image of the provided size
+// and resolution, in dots per inch.
+func renderImg(p *hplot.Plot, w, h vg.Length, dpi int) ([]byte, error) {
+	return renderCanvas(drawImg(p, w, h, dpi))
+}
+
+// renderCanvas encodes the image canvas as a PNG image.
+func renderCanvas(c *vgimg.Canvas) ([]byte, error) {
+	canvas := vgimg.PngCanvas{Canvas: c}
 	out := new(bytes.Buffer)
 	_, err := canvas.WriteTo(out)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("mcpi: could not encode image: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// drawImg draws the plot p on an image canvas, filled with the background
+// color of p.
+func drawImg(p *hplot.Plot, w, h vg.Length, dpi int) *vgimg.Canvas {
+	bkg := p.BackgroundColor
+	if bkg == nil {
+		bkg = color.White
 	}
-	return base64.StdEncoding.EncodeToString(out.Bytes())
+	canvas := vgimg.NewWith(
+		vgimg.UseWH(w, h),
+		vgimg.UseDPI(dpi),
+		vgimg.UseBackgroundColor(bkg),
+	)
+	p.Draw(draw.New(canvas))
+	return canvas
 }
 
+// wplot is a frame sent to the web client.
+// The estimate of a frame without points is encoded as null.
 type wplot struct {
-	Plot string `json:"plot"`
+	N    int       `json:"n"`             // number of points
+	Pi   float64   `json:"pi"`            // estimate of Pi
+	Time time.Time `json:"time"`          // emission time
+	Plot string    `json:"plot"`          // base64-encoded PNG image
+	Seq  int       `json:"seq,omitempty"` // sequence number of the PNG image, see WithFrameURLs
+
+	Domain [2]float64 `json:"domain"` // [min,max] range of the sampled square
+
+	In  [][2]float64 `json:"in,omitempty"`  // inside points, for client-side rendering
+	Out [][2]float64 `json:"out,omitempty"` // outside points, for client-side rendering
+
+	Done bool `json:"done,omitempty"` // whether this is the final frame
+
+	png []byte // PNG image, for in-process observers, see Frames
+}
+
+func (f wplot) MarshalJSON() ([]byte, error) {
+	type frame wplot // without the MarshalJSON method
+	v := struct {
+		frame
+		Pi *float64 `json:"pi"`
+	}{frame: frame(f)}
+	if !math.IsNaN(f.Pi) {
+		v.Pi = &f.Pi
+	}
+	return json.Marshal(v)
+}
+
+func (f *wplot) UnmarshalJSON(data []byte) error {
+	type frame wplot // without the UnmarshalJSON method
+	v := struct {
+		*frame
+		Pi *float64 `json:"pi"`
+	}{frame: (*frame)(f)}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	f.Pi = math.NaN()
+	if v.Pi != nil {
+		f.Pi = *v.Pi
+	}
+	return nil
+}
+
+// listen starts serving the web plot server on a free TCP port.
+func (srv *server) listen() error {
+	logger := srv.logger()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.web != nil {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return fmt.Errorf("mcpi: could not listen: %w", err)
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	ip, err := getIP()
+	if err != nil {
+		logger.Warn("could not find outbound IP address", "err", err)
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+	logger.Info("listening on " + ip.String() + ":" + port)
+
+	srv.serve(l, logger)
+	return nil
 }
 
-func (srv *server) serve() {
-	port, err := getTCPPort()
+// listenUnix starts serving the web plot server on the Unix domain socket
+// at path.
+func (srv *server) listenUnix(path string) error {
+	logger := srv.logger()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.web != nil {
+		return errors.New("mcpi: web-server already started")
+	}
+
+	// remove the socket left over by a previous run, but no other file.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("mcpi: could not remove stale socket: %w", err)
+		}
+	}
+	l, err := net.Listen("unix", path)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("mcpi: could not listen: %w", err)
+	}
+	logger.Info("listening on unix:" + path)
+
+	srv.serve(l, logger)
+	return nil
+}
+
+// serve serves the web plot server on l.
+// serve must be called with srv.mu held.
+func (srv *server) serve(l net.Listener, logger *slog.Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.web = &http.Server{
+		Handler:     srv,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	srv.cancel = cancel
+	srv.ln = l
+
+	go func(web *http.Server) {
+		err := web.Serve(l)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("error running web-server", "err", err)
+		}
+	}(srv.web)
+}
+
+// autostart starts the web plot server on first use, unless the plot
+// handler has been mounted into another server.
+// autostart returns the error of the listener, logged on first use: the
+// points are still accounted for, but can not be viewed.
+func (srv *server) autostart() error {
+	srv.once.Do(func() {
+		srv.mu.Lock()
+		mounted := srv.mounted
+		srv.mu.Unlock()
+		if mounted {
+			return
+		}
+		srv.lerr = srv.listen()
+		if srv.lerr != nil {
+			srv.logger().Error("could not start web-server", "err", srv.lerr)
+		}
+	})
+	return srv.lerr
+}
+
+// close stops the web plot server listener and disconnects its clients.
+func (srv *server) close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.web == nil {
+		return nil
 	}
-	ip := getIP()
-	log.Printf("listening on %s:%s", ip, port)
 
-	http.HandleFunc("/", plotHandle)
-	http.Handle("/data", websocket.Handler(dataHandler))
-	err = http.ListenAndServe(":"+port, nil)
+	srv.cancel()
+	err := srv.web.Close()
+	// close the listener even if web has not started serving it yet, e.g.
+	// to remove the socket of ServeUnix right away; it may already be closed.
+	_ = srv.ln.Close()
+	srv.web = nil
+	srv.ln = nil
+	srv.cancel = nil
 	if err != nil {
-		log.Fatalf("error running web-server: %v", err)
+		return fmt.Errorf("mcpi: could not close web-server: %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP serves the plot page and its data under the configured base path.
+func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cfg := srv.config()
+	if origin := r.Header.Get("Origin"); origin != "" && cfg.allowOrigin(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			if h := r.Header.Get("Access-Control-Request-Headers"); h != "" {
+				w.Header().Set("Access-Control-Allow-Headers", h)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	if cfg.user != "" && !cfg.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="mcpi", charset="UTF-8"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	base := cfg.base
+	switch {
+	case r.URL.Path == "":
+		// the handler is mounted under a prefix that was stripped off (see Handler.)
+		path, _, _ := strings.Cut(r.RequestURI, "?")
+		http.Redirect(w, r, withQuery(path+"/", r), http.StatusMovedPermanently)
+	case base == "":
+		srv.mux.ServeHTTP(w, r)
+	case r.URL.Path == base:
+		http.Redirect(w, r, withQuery(base+"/", r), http.StatusMovedPermanently)
+	case strings.HasPrefix(r.URL.Path, base+"/"):
+		http.StripPrefix(base, srv.mux).ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// withQuery returns the URL path with the query of the request r, if any.
+func withQuery(path string, r *http.Request) string {
+	if r.URL.RawQuery == "" {
+		return path
+	}
+	return path + "?" + r.URL.RawQuery
+}
+
+// authorized returns whether the request carries the configured basic-auth credentials.
+func (cfg config) authorized(r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
 	}
+	okUser := subtle.ConstantTimeCompare([]byte(user), []byte(cfg.user)) == 1
+	okPass := subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.pass)) == 1
+	return okUser && okPass
 }
 
-func plotHandle(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, page)
+func (srv *server) plotHandle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		// "/" matches all the paths without a more specific handler.
+		http.NotFound(w, r)
+		return
+	}
+	cfg := srv.config()
+	tmpl := pageTmpl
+	if cfg.assets != "" {
+		tmpl = wasmPageTmpl
+	}
+	err := tmpl.Execute(w, struct{ Name, Run string }{cfg.name, srv.pngs.run})
+	if err != nil {
+		srv.logger().Error("error executing page template", "err", err)
+	}
 	select {
 	case srv.wait <- 1:
 	default:
 	}
 }
 
-func dataHandler(ws *websocket.Conn) {
-	for data := range srv.plots {
-		err := websocket.JSON.Send(ws, data)
-		if err != nil {
-			log.Printf("error sending data: %v\n", err)
+// faviconHandle answers the browsers requesting the page icon, which the
+// plot page does not have, without a body.
+func faviconHandle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "max-age=86400")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// jsonBody rejects, with a 415 status, the requests whose body is not
+// declared as JSON, and returns whether the request was accepted.
+// Contrary to the forms and simple requests of other sites, declaring a JSON
+// body requires a CORS preflight, which only the allowed origins pass: this
+// protects the endpoints updating the state against cross-site requests.
+func jsonBody(w http.ResponseWriter, r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mt != "application/json" {
+		http.Error(w, "mcpi: request body must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+// checkOrigin rejects websocket connections from other origins,
+// unless they have been explicitly allowed.
+func (srv *server) checkOrigin(cfg *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(cfg, r)
+	if err != nil {
+		return err
+	}
+	cfg.Origin = origin
+	if origin == nil || origin.Host == r.Host {
+		return nil
+	}
+	if srv.config().allowOrigin(origin.Scheme + "://" + origin.Host) {
+		return nil
+	}
+	return fmt.Errorf("mcpi: origin %q not allowed", origin)
+}
+
+func (srv *server) dataHandler(ws *websocket.Conn) {
+	frames := srv.hub.register()
+	defer srv.hub.unregister(frames)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		// the client never sends anything: this returns when the connection
+		// is closed, or when the client stops answering the pings.
+		_, err := io.Copy(io.Discard, ws)
+		var nerr net.Error
+		if errors.As(err, &nerr) && nerr.Timeout() {
+			srv.logger().Info("web client timed out", "addr", ws.Request().RemoteAddr)
+		}
+	}()
+
+	cfg := srv.config()
+	var pings <-chan time.Time
+	if cfg.ping > 0 {
+		ticker := time.NewTicker(cfg.ping)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+	write := func(f func() error) error {
+		if cfg.timeout > 0 {
+			err := ws.SetWriteDeadline(time.Now().Add(cfg.timeout))
+			if err != nil {
+				return err
+			}
+		}
+		return f()
+	}
+
+	for {
+		select {
+		case frame := <-frames:
+			err := write(func() error { return websocket.JSON.Send(ws, frame) })
+			if err != nil {
+				srv.logger().Error("error sending data", "err", err)
+				return
+			}
+		case <-pings:
+			err := write(func() error { return ping(ws) })
+			if err != nil {
+				srv.logger().Error("error sending ping", "err", err)
+				return
+			}
+		case <-closed:
+			return
+		case <-ws.Request().Context().Done():
+			return
 		}
 	}
 }
@@ -248,24 +1899,86 @@ func dataHandler(ws *websocket.Conn) {
 const page = `
 <html>
 	<head>
-		<title>Monte Carlo</title>
+		<title>{{with .Name}}{{.}} - {{end}}Monte Carlo</title>
 		<script type="text/javascript">
 		var sock = null;
-		var plot = "";
+		var src = "";
+		var paused = false;
+		var heatmap = false;
 
 		function update() {
 			var p = document.getElementById("plot");
-			p.src = "data:image/png;base64,"+plot;
+			p.src = src;
 		};
 
 		window.onload = function() {
-			sock = new WebSocket("ws://"+location.host+"/data");
+			// resolve the data endpoint relative to the page, so the page works
+			// under a base path or when mounted in another server.
+			var url = new URL("data", location.href);
+			url.protocol = url.protocol.replace("http", "ws");
+			sock = new WebSocket(url.href);
 
 			sock.onmessage = function(event) {
 				var data = JSON.parse(event.data);
-				plot = data.plot;
+				if (data.seq) {
+					src = "frame/{{.Run}}/"+data.seq+".png";
+				} else {
+					src = "data:image/png;base64,"+data.plot;
+				}
 				update();
+				if (data.done) {
+					var pi = data.pi === null ? "n/a" : data.pi;
+					document.getElementById("status").textContent =
+						"Simulation complete — π = " + pi + " (n=" + data.n + ")";
+				}
 			};
+
+			// presenter shortcuts: F toggles fullscreen, space pauses and
+			// resumes the simulation, S saves the current image, H switches
+			// between the scatter and the heatmap.
+			document.addEventListener("keydown", function(event) {
+				switch (event.key) {
+				case "f":
+				case "F":
+					if (document.fullscreenElement) {
+						document.exitFullscreen();
+					} else {
+						document.documentElement.requestFullscreen();
+					}
+					break;
+				case " ":
+					event.preventDefault();
+					fetch("control", {
+						method: "POST",
+						headers: {"Content-Type": "application/json"},
+						body: JSON.stringify({paused: !paused}),
+					}).then(function(resp) {
+						return resp.json();
+					}).then(function(state) {
+						paused = state.paused;
+					});
+					break;
+				case "h":
+				case "H":
+					fetch("config", {
+						method: "POST",
+						headers: {"Content-Type": "application/json"},
+						body: JSON.stringify({heatmap: !heatmap}),
+					}).then(function(resp) {
+						return resp.json();
+					}).then(function(cfg) {
+						heatmap = cfg.heatmap;
+					});
+					break;
+				case "s":
+				case "S":
+					var a = document.createElement("a");
+					a.href = "preview.png";
+					a.download = "mcpi.png";
+					a.click();
+					break;
+				}
+			});
 		};
 
 		</script>
@@ -276,32 +1989,25 @@ const page = `
 			<p style="text-align:center;">
 				<img id="plot" src="" alt="Not Available"></img>
 			</p>
+			<p id="status" style="text-align:center;"></p>
+			<p style="text-align:center;">
+				<a href="render?size=40cm&dpi=192" download="mcpi.png">Download a high-resolution image</a>
+			</p>
 		</div>
 	</body>
 </html>
 `
 
-func getTCPPort() (string, error) {
-	addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
-	if err != nil {
-		return "", err
-	}
-	l, err := net.ListenTCP("tcp", addr)
-	if err != nil {
-		return "", err
-	}
-	defer l.Close()
-	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port), nil
-}
+var pageTmpl = template.Must(template.New("page").Parse(page))
 
-func getIP() net.IP {
+func getIP() (net.IP, error) {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer conn.Close()
 
 	localAddr := conn.LocalAddr().(*net.UDPAddr)
 
-	return localAddr.IP
+	return localAddr.IP, nil
 }